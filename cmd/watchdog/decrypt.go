@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/kornelkabele/watchdog/internal/crypt"
+)
+
+// runDecrypt handles "decrypt" subcommand restoring encrypted images and uploads
+func runDecrypt(args []string) {
+	fs := flag.NewFlagSet("decrypt", flag.ExitOnError)
+	identity := fs.String("identity", "", "age identity file with private key")
+	out := fs.String("out", "", "writes decrypted files to directory instead of next to encrypted ones")
+	fs.Parse(args)
+	if *identity == "" || fs.NArg() == 0 {
+		fmt.Println("Usage: watchdog decrypt --identity key.txt [--out dir] file|dir...")
+		os.Exit(2)
+	}
+
+	identities, err := crypt.LoadIdentities(*identity)
+	if err != nil {
+		log.Fatalf("Cannot load identity: %s\n", err)
+	}
+	failed := false
+	for _, root := range fs.Args() {
+		err := filepath.Walk(root, func(path string, fi os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if fi.IsDir() || !crypt.IsEncrypted(path) {
+				return nil
+			}
+			if err := decrypt(root, path, *out, identities); err != nil {
+				log.Printf("Failed to decrypt %s: %s\n", path, err)
+				failed = true
+			}
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to decrypt %s: %s\n", root, err)
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// decrypt restores file without .age suffix, encrypted local images keep their name and are decrypted in place
+func decrypt(root, path, out string, identities []age.Identity) error {
+	dst := strings.TrimSuffix(path, crypt.Suffix)
+	if out != "" {
+		rel, err := filepath.Rel(root, dst)
+		if err != nil || rel == "." {
+			rel = filepath.Base(dst)
+		}
+		dst = filepath.Join(out, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			return err
+		}
+	}
+	if err := crypt.DecryptFile(path, dst, identities); err != nil {
+		return err
+	}
+	fmt.Println(dst)
+	return nil
+}
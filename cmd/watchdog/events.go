@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/index"
+)
+
+// runEvents handles "events" subcommand querying event index
+func runEvents(args []string) {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Println("Usage: watchdog events list [--camera id] [--since 24h] [--min-score 0.2] [--frames]")
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet("events list", flag.ExitOnError)
+	camera := fs.String("camera", "", "lists only records of given camera id")
+	since := fs.Duration("since", 24*time.Hour, "lists records younger than given duration")
+	minScore := fs.Float64("min-score", 0, "lists records with at least given similarity index")
+	frames := fs.Bool("frames", false, "lists kept frames instead of events")
+	fs.Parse(args[1:])
+
+	cfg.LoadConfig(ConfigFile)
+	filter := index.Filter{
+		Camera:   *camera,
+		Since:    time.Now().Add(-*since),
+		MinScore: float32(*minScore),
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	if *frames {
+		records, err := index.Frames(filter)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Fprintln(w, "TIME\tCAMERA\tSCORE\tEVENT\tACTIONS\tUPLOAD\tEMAIL\tPATH")
+		for _, f := range records {
+			fmt.Fprintf(w, "%s\t%s\t%.2f\t%s\t%s\t%s\t%s\t%s\n", f.Time.Format(time.RFC3339), f.Camera, f.Score,
+				f.Event, strings.Join(f.Actions, ","), f.Upload, f.Email, f.Path)
+		}
+		return
+	}
+
+	records, err := index.Events(filter)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Fprintln(w, "ID\tCAMERA\tSTART\tEND\tFRAMES\tMAX SCORE\tACTIONS\tUPLOAD\tEMAIL")
+	for _, e := range records {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%.2f\t%s\t%s\t%s\n", e.ID, e.Camera, e.Start.Format(time.RFC3339),
+			e.End.Format(time.RFC3339), e.Frames, e.MaxScore, strings.Join(e.Actions, ","), e.Upload, e.Email)
+	}
+}
@@ -8,12 +8,20 @@ import (
 	"path/filepath"
 	"time"
 
+	"github.com/kornelkabele/watchdog/internal/bot"
 	"github.com/kornelkabele/watchdog/internal/cfg"
-	"github.com/kornelkabele/watchdog/internal/email"
+	"github.com/kornelkabele/watchdog/internal/crypt"
 	"github.com/kornelkabele/watchdog/internal/file"
+	"github.com/kornelkabele/watchdog/internal/index"
 	"github.com/kornelkabele/watchdog/internal/logger"
+	"github.com/kornelkabele/watchdog/internal/mqtt"
+	"github.com/kornelkabele/watchdog/internal/notify"
 	"github.com/kornelkabele/watchdog/internal/process"
+	"github.com/kornelkabele/watchdog/internal/queue"
+	"github.com/kornelkabele/watchdog/internal/storage"
 	"github.com/kornelkabele/watchdog/internal/system"
+	"github.com/kornelkabele/watchdog/internal/timelapse"
+	"github.com/kornelkabele/watchdog/internal/upload"
 )
 
 var (
@@ -52,13 +60,28 @@ func main() {
 		log.Fatal(err)
 	}
 
+	if len(os.Args) > 1 && os.Args[1] == "events" {
+		runEvents(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "queue" {
+		runQueue(os.Args[2:])
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "decrypt" {
+		runDecrypt(os.Args[2:])
+		return
+	}
+
 	parseFlags()
 	cfg.LoadConfig(ConfigFile)
 	fmt.Printf("Settings:\n")
 	fmt.Printf("Id: %s\n", cfg.Settings.Id)
 	fmt.Printf("Camera: %s:%d\n", cfg.Camera.Host, cfg.Camera.Port)
 	fmt.Printf("Email: %s:%d\n", cfg.SMTP.Host, cfg.SMTP.Port)
-	fmt.Printf("FTP: %s:%d\n", cfg.FTP.Host, cfg.FTP.Port)
+	for _, t := range cfg.Targets {
+		fmt.Printf("Upload target: %s (%s)\n", t.Name, t.Type)
+	}
 	fmt.Printf("Image dir: %s\n", cfg.Settings.ImageDir)
 	fmt.Printf("Log file: %s\n", cfg.Settings.LogFile)
 
@@ -67,13 +90,53 @@ func main() {
 		log.Fatalf("Cannot set logger: %s\n", err)
 	}
 	defer logger.Close()
-	system.SigIntHook(func() { logger.Close() })
+	if err := index.Init(); err != nil {
+		log.Fatalf("Cannot open event index: %s\n", err)
+	}
+	defer index.Close()
+	if err := queue.Init(); err != nil {
+		log.Fatalf("Cannot open upload queue: %s\n", err)
+	}
+	defer queue.Close()
+	system.SigIntHook(func() {
+		mqtt.Stop()
+		upload.Close()
+		index.Close()
+		queue.Close()
+		logger.Close()
+	})
 
+	if err := crypt.Init(); err != nil {
+		log.Fatalf("Cannot load encryption recipients: %s\n", err)
+	}
+	upload.Init()
+	defer upload.Close()
+	notify.Init()
 	system.WaitNetworkAvailable()
 	createImageDir()
-	email.SendEmail(fmt.Sprintf("CAMERA START: %s", cfg.Settings.Id),
-		fmt.Sprintf("%s Camera started", time.Now().Format(time.RFC3339)),
-		nil)
+	if crypt.Local() {
+		if err := crypt.EncryptImages(cfg.Settings.ImageDir); err != nil {
+			log.Printf("Failed to encrypt stored images: %s\n", err)
+		}
+	}
+	if _, err := notify.Send(notify.New(notify.KindStart, time.Now(), "Camera started")); err != nil {
+		log.Printf("Failed to send start notification: %s\n", err)
+	}
+
+	bot.Start()
+	mqtt.Start()
+	go storage.ScheduleRetention()
+	go storage.GuardDisk()
+	go upload.DrainQueue()
+	go upload.ScheduleManifests()
+	for _, t := range upload.Targets {
+		if t.Prune.Interval > 0 {
+			go upload.SchedulePrune(t)
+		}
+	}
+	if cfg.Timelapse.Enabled {
+		go timelapse.Schedule()
+	}
 
 	// main loop
 	for {
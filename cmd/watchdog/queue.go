@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/queue"
+)
+
+// runQueue handles "queue" subcommand listing uploads waiting for retry
+func runQueue(args []string) {
+	if len(args) == 0 || args[0] != "list" {
+		fmt.Println("Usage: watchdog queue list")
+		os.Exit(2)
+	}
+
+	cfg.LoadConfig(ConfigFile)
+	items, err := queue.Items()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if len(items) > 0 {
+		fmt.Printf("%d uploads queued, oldest %s ago\n", len(items), time.Since(items[0].Added).Round(time.Second))
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+	fmt.Fprintln(w, "ADDED\tTARGET\tATTEMPTS\tPATH\tLAST ERROR")
+	for _, item := range items {
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%s\n", item.Added.Format(time.RFC3339), item.Target, item.Attempts, item.Src, item.LastError)
+	}
+}
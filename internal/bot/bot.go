@@ -0,0 +1,189 @@
+package bot
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/crypt"
+	"github.com/kornelkabele/watchdog/internal/notify"
+	"github.com/kornelkabele/watchdog/internal/process"
+	"github.com/kornelkabele/watchdog/internal/queue"
+	"github.com/kornelkabele/watchdog/internal/storage"
+)
+
+const (
+	// pollTimeout is how long getUpdates request waits for messages
+	pollTimeout = 30 * time.Second
+	// pollRetry is pause after failed poll
+	pollRetry = 10 * time.Second
+	// maxAge skips commands sent long before watchdog started polling
+	maxAge = 5 * time.Minute
+)
+
+const usage = "Commands: /snapshot, /arm, /disarm, /status, /mute 1h, /last"
+
+// Bot executes commands received from whitelisted Telegram chats
+type Bot struct {
+	name    string
+	tg      *notify.Telegram
+	allowed map[int64]bool
+}
+
+// New creates bot of telegram notifier, chat of notifier is allowed when it is numeric
+func New(c cfg.ConfigNotifier) *Bot {
+	b := &Bot{name: c.Name, tg: notify.NewTelegram(c), allowed: map[int64]bool{}}
+	if id, err := strconv.ParseInt(c.ChatID, 10, 64); err == nil {
+		b.allowed[id] = true
+	}
+	for _, id := range c.AllowedChats {
+		b.allowed[id] = true
+	}
+	return b
+}
+
+// Start polls commands of every telegram notifier with commands enabled
+func Start() {
+	for _, c := range cfg.Notifiers {
+		if c.Type == "telegram" && c.Commands {
+			go New(c).Poll()
+		}
+	}
+}
+
+// Poll receives commands using long polling so that no port needs to be open, it never returns
+func (b *Bot) Poll() {
+	var offset int64
+	for {
+		updates, err := b.tg.Updates(offset, pollTimeout)
+		if err != nil {
+			log.Printf("Failed to receive commands from %s: %s\n", b.name, err)
+			time.Sleep(pollRetry)
+			continue
+		}
+		for _, u := range updates {
+			offset = u.ID + 1
+			if u.Message == nil {
+				continue
+			}
+			if time.Since(time.Unix(u.Message.Date, 0)) > maxAge {
+				log.Printf("Skipping stale command %q from chat %d\n", u.Message.Text, u.Message.Chat.ID)
+				continue
+			}
+			b.Handle(u.Message.Chat.ID, u.Message.Text)
+		}
+	}
+}
+
+// Handle executes command from chat and replies to it
+func (b *Bot) Handle(chat int64, text string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], "/") {
+		return
+	}
+	if !b.allowed[chat] {
+		log.Printf("Ignoring command %q from chat %d which is not allowed\n", fields[0], chat)
+		return
+	}
+	// commands in groups may be addressed as /status@bot
+	command := strings.SplitN(fields[0], "@", 2)[0]
+	log.Printf("Command %s from chat %d\n", command, chat)
+
+	id := strconv.FormatInt(chat, 10)
+	var err error
+	switch command {
+	case "/snapshot":
+		err = b.snapshot(id)
+	case "/arm":
+		process.Arm(true)
+		err = b.tg.SendMessage(id, "Armed")
+	case "/disarm":
+		process.Arm(false)
+		err = b.tg.SendMessage(id, "Disarmed, frames are still stored and uploaded")
+	case "/mute":
+		err = b.tg.SendMessage(id, mute(fields[1:]))
+	case "/status":
+		err = b.tg.SendMessage(id, status())
+	case "/last":
+		err = b.last(id)
+	default:
+		err = b.tg.SendMessage(id, usage)
+	}
+	if err != nil {
+		log.Printf("Failed to reply to %s: %s\n", command, err)
+	}
+}
+
+func (b *Bot) snapshot(chat string) error {
+	path, err := process.Snapshot()
+	if err != nil {
+		return b.tg.SendMessage(chat, fmt.Sprintf("Failed to capture snapshot: %s", err))
+	}
+	defer os.Remove(path)
+	return b.tg.SendFile(chat, path, fmt.Sprintf("Snapshot: %s\n%s", cfg.Settings.Id, time.Now().Format(time.RFC3339)))
+}
+
+func (b *Bot) last(chat string) error {
+	f := process.LastFrame()
+	if f.Path == "" {
+		return b.tg.SendMessage(chat, "No frame kept yet")
+	}
+	caption := fmt.Sprintf("Last frame: %s\n%s\nscore %.2f", cfg.Settings.Id, f.Time.Format(time.RFC3339), f.Score)
+	if _, err := os.Stat(f.Path); err != nil {
+		return b.tg.SendMessage(chat, caption+"\nimage was removed")
+	}
+	if crypt.IsEncrypted(f.Path) {
+		return b.tg.SendMessage(chat, caption+"\nimage is encrypted")
+	}
+	return b.tg.SendFile(chat, f.Path, caption)
+}
+
+// mute mutes notifications for given duration, one hour by default, zero unmutes
+func mute(args []string) string {
+	d := time.Hour
+	if len(args) > 0 {
+		var err error
+		if d, err = time.ParseDuration(args[0]); err != nil || d < 0 {
+			return "Usage: /mute 1h, /mute 30m or /mute 0 to unmute"
+		}
+	}
+	if d == 0 {
+		notify.Mute(time.Time{})
+		return "Notifications unmuted"
+	}
+	until := time.Now().Add(d)
+	notify.Mute(until)
+	return fmt.Sprintf("Notifications muted until %s", until.Format(time.RFC3339))
+}
+
+func status() string {
+	lines := []string{"Camera: " + cfg.Settings.Id}
+	if process.Armed() {
+		lines = append(lines, "Armed: yes")
+	} else {
+		lines = append(lines, "Armed: no")
+	}
+	if until := notify.MutedUntil(); time.Now().Before(until) {
+		lines = append(lines, "Muted until: "+until.Format(time.RFC3339))
+	}
+	if f := process.LastFrame(); f.Path != "" {
+		lines = append(lines, fmt.Sprintf("Last frame: %s score %.2f", f.Time.Format(time.RFC3339), f.Score))
+	} else {
+		lines = append(lines, "Last frame: none")
+	}
+	if count, oldest, err := queue.Stats(); err != nil {
+		lines = append(lines, fmt.Sprintf("Upload queue: %s", err))
+	} else if count > 0 {
+		lines = append(lines, fmt.Sprintf("Upload queue: %d since %s", count, oldest.Format(time.RFC3339)))
+	} else {
+		lines = append(lines, "Upload queue: empty")
+	}
+	if storage.AlertOnly() {
+		lines = append(lines, "Storage: disk low, storing alert images only")
+	}
+	return strings.Join(lines, "\n")
+}
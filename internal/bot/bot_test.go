@@ -0,0 +1,72 @@
+package bot
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/notify"
+	"github.com/kornelkabele/watchdog/internal/process"
+)
+
+func TestHandle(t *testing.T) {
+	var replies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replies = append(replies, r.FormValue("chat_id")+" "+r.FormValue("text"))
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	}))
+	defer server.Close()
+	cfg.Settings.Id = "garden"
+	b := New(cfg.ConfigNotifier{Name: "telegram", URL: server.URL, Token: "1:a", ChatID: "42", AllowedChats: []int64{7}})
+
+	b.Handle(13, "/disarm")
+	if !process.Armed() || len(replies) != 0 {
+		t.Fatalf("command from unknown chat was executed: %v", replies)
+	}
+
+	b.Handle(42, "/disarm")
+	if process.Armed() {
+		t.Fatal("camera should be disarmed")
+	}
+	b.Handle(7, "/status@watchdog_bot")
+	b.Handle(42, "/arm")
+	if !process.Armed() {
+		t.Fatal("camera should be armed")
+	}
+
+	b.Handle(42, "/mute 2h")
+	if until := notify.MutedUntil(); until.Sub(time.Now()) < 119*time.Minute {
+		t.Fatalf("unexpected mute until %s", until)
+	}
+	b.Handle(42, "/mute 0")
+	if !notify.MutedUntil().IsZero() {
+		t.Fatal("notifications should be unmuted")
+	}
+	b.Handle(42, "/mute soon")
+	b.Handle(42, "/last")
+	b.Handle(42, "hello")
+	b.Handle(42, "/help")
+
+	want := []string{
+		"42 Disarmed, frames are still stored and uploaded",
+		"7 Camera: garden\nArmed: no\nLast frame: none\nUpload queue: empty",
+		"42 Armed",
+		"42 Notifications muted until",
+		"42 Notifications unmuted",
+		"42 Usage: /mute 1h, /mute 30m or /mute 0 to unmute",
+		"42 No frame kept yet",
+		"42 " + usage,
+	}
+	if len(replies) != len(want) {
+		t.Fatalf("got replies %q", replies)
+	}
+	for i := range want {
+		if !strings.HasPrefix(replies[i], want[i]) {
+			t.Fatalf("reply %d is %q, want %q", i, replies[i], want[i])
+		}
+	}
+}
@@ -1,10 +1,16 @@
 package cfg
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"log"
 	"os"
+	"path"
 	"strconv"
+	"strings"
+	"time"
 
+	"github.com/kornelkabele/watchdog/internal/pathtmpl"
 	"gopkg.in/yaml.v2"
 )
 
@@ -22,13 +28,162 @@ type ConfigFTP struct {
 	Pass string `yaml:"pass"`
 }
 
+type ConfigTarget struct {
+	Name         string      `yaml:"name"`
+	Type         string      `yaml:"type"`
+	Threshold    float32     `yaml:"threshold"`
+	PathTemplate string      `yaml:"pathTemplate"`
+	Host         string      `yaml:"host"`
+	Port         int         `yaml:"port"`
+	User         string      `yaml:"user"`
+	Pass         string      `yaml:"pass"`
+	Dir          string      `yaml:"dir"`
+	KeyFile      string      `yaml:"keyFile"`
+	KnownHosts   string      `yaml:"knownHosts"`
+	Endpoint     string      `yaml:"endpoint"`
+	Bucket       string      `yaml:"bucket"`
+	Region       string      `yaml:"region"`
+	PathStyle    bool        `yaml:"pathStyle"`
+	TLSMode      string      `yaml:"tlsMode"`
+	Insecure     bool        `yaml:"insecure"`
+	Fingerprint  string      `yaml:"fingerprint"`
+	Active       bool        `yaml:"active"`
+	KeepAlive    int         `yaml:"keepAlive"`
+	Workers      int         `yaml:"workers"`
+	Manifest     string      `yaml:"manifest"`
+	Prune        ConfigPrune `yaml:"prune"`
+}
+
+type ConfigPrune struct {
+	Interval int    `yaml:"interval"`
+	Root     string `yaml:"root"`
+	MaxAge   int    `yaml:"maxAge"`
+	MaxSize  int    `yaml:"maxSize"`
+	DryRun   bool   `yaml:"dryRun"`
+}
+
+type ConfigNotifier struct {
+	Name         string            `yaml:"name"`
+	Type         string            `yaml:"type"`
+	Kinds        []string          `yaml:"kinds"`
+	MinSeverity  string            `yaml:"minSeverity"`
+	RateLimit    int               `yaml:"rateLimit"`
+	URL          string            `yaml:"url"`
+	Method       string            `yaml:"method"`
+	Headers      map[string]string `yaml:"headers"`
+	Body         string            `yaml:"body"`
+	Image        string            `yaml:"image"`
+	Retries      int               `yaml:"retries"`
+	Timeout      int               `yaml:"timeout"`
+	Token        string            `yaml:"token"`
+	ChatID       string            `yaml:"chatId"`
+	Channel      string            `yaml:"channel"`
+	Topic        string            `yaml:"topic"`
+	User         string            `yaml:"user"`
+	Pass         string            `yaml:"pass"`
+	Tags         []string          `yaml:"tags"`
+	Click        string            `yaml:"click"`
+	Priorities   []ConfigPriority  `yaml:"priorities"`
+	To           []string          `yaml:"to"`
+	CC           []string          `yaml:"cc"`
+	BCC          []string          `yaml:"bcc"`
+	Commands     bool              `yaml:"commands"`
+	AllowedChats []int64           `yaml:"allowedChats"`
+}
+
+type ConfigPriority struct {
+	Score    float32 `yaml:"score"`
+	Priority int     `yaml:"priority"`
+}
+
 type ConfigSMTP struct {
-	Host     string `yaml:"host"`
-	Port     int    `yaml:"port"`
-	User     string `yaml:"user"`
-	Pass     string `yaml:"pass"`
-	Sender   string `yaml:"sender"`
-	Receiver string `yaml:"receiver"`
+	Host         string   `yaml:"host"`
+	Port         int      `yaml:"port"`
+	User         string   `yaml:"user"`
+	Pass         string   `yaml:"pass"`
+	Sender       string   `yaml:"sender"`
+	Receiver     string   `yaml:"receiver"`
+	To           []string `yaml:"to"`
+	CC           []string `yaml:"cc"`
+	BCC          []string `yaml:"bcc"`
+	Subject      string   `yaml:"subject"`
+	TextTemplate string   `yaml:"textTemplate"`
+	HTMLTemplate string   `yaml:"htmlTemplate"`
+}
+
+type ConfigMQTT struct {
+	Enabled         bool   `yaml:"enabled"`
+	Broker          string `yaml:"broker"`
+	User            string `yaml:"user"`
+	Pass            string `yaml:"pass"`
+	ClientID        string `yaml:"clientId"`
+	TopicPrefix     string `yaml:"topicPrefix"`
+	Discovery       bool   `yaml:"discovery"`
+	DiscoveryPrefix string `yaml:"discoveryPrefix"`
+	MotionTimeout   int    `yaml:"motionTimeout"`
+}
+
+type ConfigClip struct {
+	Enabled   bool   `yaml:"enabled"`
+	Source    string `yaml:"source"`
+	Length    int    `yaml:"length"`
+	FrameRate int    `yaml:"frameRate"`
+	Preset    string `yaml:"preset"`
+	MaxWidth  int    `yaml:"maxWidth"`
+	MaxSize   int    `yaml:"maxSize"`
+	FramesCmd string `yaml:"framesCmd"`
+	RecordCmd string `yaml:"recordCmd"`
+}
+
+type ConfigMontage struct {
+	Enabled   bool `yaml:"enabled"`
+	Columns   int  `yaml:"columns"`
+	TileWidth int  `yaml:"tileWidth"`
+	MaxTiles  int  `yaml:"maxTiles"`
+}
+
+type ConfigTimelapse struct {
+	Enabled        bool   `yaml:"enabled"`
+	Hour           int    `yaml:"hour"`
+	Format         string `yaml:"format"`
+	SampleInterval int    `yaml:"sampleInterval"`
+	IdleInterval   int    `yaml:"idleInterval"`
+	FrameRate      int    `yaml:"frameRate"`
+	Preset         string `yaml:"preset"`
+	MaxWidth       int    `yaml:"maxWidth"`
+	VideoCmd       string `yaml:"videoCmd"`
+}
+
+type ConfigRetention struct {
+	Interval    int `yaml:"interval"`
+	MaxAge      int `yaml:"maxAge"`
+	AlertMaxAge int `yaml:"alertMaxAge"`
+	MaxSize     int `yaml:"maxSize"`
+	MaxFiles    int `yaml:"maxFiles"`
+}
+
+type ConfigStorage struct {
+	PathTemplate string          `yaml:"pathTemplate"`
+	Retention    ConfigRetention `yaml:"retention"`
+}
+
+type ConfigDisk struct {
+	Interval      int `yaml:"interval"`
+	LowWatermark  int `yaml:"lowWatermark"`
+	HighWatermark int `yaml:"highWatermark"`
+}
+
+type ConfigEncryption struct {
+	Recipients     []string `yaml:"recipients"`
+	RecipientsFile string   `yaml:"recipientsFile"`
+	Uploads        bool     `yaml:"uploads"`
+	Local          bool     `yaml:"local"`
+}
+
+type ConfigDelivery struct {
+	QueueSize     int `yaml:"queueSize"`
+	Workers       int `yaml:"workers"`
+	NotifyWorkers int `yaml:"notifyWorkers"`
 }
 
 type ConfigSettings struct {
@@ -38,17 +193,33 @@ type ConfigSettings struct {
 	UploadThreshold float32 `yaml:"uploadThreshold"`
 	EmailThreshold  float32 `yaml:"emailThreshold"`
 	EmailInterval   int     `yaml:"emailInterval"`
+	TamperThreshold float32 `yaml:"tamperThreshold"`
+	EventGap        int     `yaml:"eventGap"`
+	Sidecar         bool    `yaml:"sidecar"`
 	ImageDir        string  `yaml:"imageDir"`
 	LogFile         string  `yaml:"logFile"`
+	IndexFile       string  `yaml:"indexFile"`
+	QueueFile       string  `yaml:"queueFile"`
+	ManifestDir     string  `yaml:"manifestDir"`
 	FFmpegCmd       string  `yaml:"ffmpegCmd"`
 }
 
 // Config contains configuration
 type Config struct {
-	Camera   ConfigCamera   `yaml:"camera"`
-	FTP      ConfigFTP      `yaml:"ftp"`
-	SMTP     ConfigSMTP     `yaml:"smtp"`
-	Settings ConfigSettings `yaml:"settings"`
+	Camera     ConfigCamera     `yaml:"camera"`
+	FTP        ConfigFTP        `yaml:"ftp"`
+	Targets    []ConfigTarget   `yaml:"targets"`
+	SMTP       ConfigSMTP       `yaml:"smtp"`
+	Notifiers  []ConfigNotifier `yaml:"notifiers"`
+	MQTT       ConfigMQTT       `yaml:"mqtt"`
+	Clip       ConfigClip       `yaml:"clip"`
+	Montage    ConfigMontage    `yaml:"montage"`
+	Timelapse  ConfigTimelapse  `yaml:"timelapse"`
+	Storage    ConfigStorage    `yaml:"storage"`
+	Disk       ConfigDisk       `yaml:"disk"`
+	Delivery   ConfigDelivery   `yaml:"delivery"`
+	Encryption ConfigEncryption `yaml:"encryption"`
+	Settings   ConfigSettings   `yaml:"settings"`
 }
 
 var (
@@ -56,8 +227,28 @@ var (
 	Camera ConfigCamera
 	// FTP configuration
 	FTP ConfigFTP
+	// Targets configuration of upload destinations
+	Targets []ConfigTarget
 	// SMTP configuration
 	SMTP ConfigSMTP
+	// Notifiers configuration
+	Notifiers []ConfigNotifier
+	// MQTT configuration
+	MQTT ConfigMQTT
+	// Clip configuration
+	Clip ConfigClip
+	// Montage configuration
+	Montage ConfigMontage
+	// Timelapse configuration
+	Timelapse ConfigTimelapse
+	// Storage configuration
+	Storage ConfigStorage
+	// Disk configuration
+	Disk ConfigDisk
+	// Delivery configuration
+	Delivery ConfigDelivery
+	// Encryption configuration
+	Encryption ConfigEncryption
 	// Settings configuration
 	Settings ConfigSettings
 )
@@ -78,11 +269,24 @@ func LoadConfig(configFile string) {
 	}
 
 	loadEnvSecrets(&cfg)
+	addDefaults(&cfg)
+	addDefaultTarget(&cfg)
+	addDefaultNotifier(&cfg)
 	validateConfig(&cfg)
 
 	Camera = cfg.Camera
 	FTP = cfg.FTP
+	Targets = cfg.Targets
 	SMTP = cfg.SMTP
+	Notifiers = cfg.Notifiers
+	MQTT = cfg.MQTT
+	Clip = cfg.Clip
+	Montage = cfg.Montage
+	Timelapse = cfg.Timelapse
+	Storage = cfg.Storage
+	Disk = cfg.Disk
+	Delivery = cfg.Delivery
+	Encryption = cfg.Encryption
 	Settings = cfg.Settings
 }
 
@@ -150,6 +354,66 @@ func loadEnvSecrets(cfg *Config) {
 	}
 }
 
+// addDefaults fills settings missing in configuration files of previous versions
+func addDefaults(cfg *Config) {
+	if cfg.Settings.IndexFile == "" {
+		cfg.Settings.IndexFile = "./watchdog.db"
+	}
+	if cfg.Settings.QueueFile == "" {
+		cfg.Settings.QueueFile = "./queue.db"
+	}
+	// previous versions stored images in weekday and hour directories and removed them a week later
+	if cfg.Storage.PathTemplate == "" {
+		cfg.Storage.PathTemplate = "0%w/0%w%H-{{.Seq}}.jpg"
+	}
+	if cfg.Storage.Retention == (ConfigRetention{}) {
+		cfg.Storage.Retention = ConfigRetention{MaxAge: 168, AlertMaxAge: 168}
+	}
+	if cfg.Storage.Retention.Interval == 0 {
+		cfg.Storage.Retention.Interval = 600
+	}
+	if cfg.Disk == (ConfigDisk{}) {
+		cfg.Disk = ConfigDisk{LowWatermark: 200, HighWatermark: 500}
+	}
+	if cfg.Disk.Interval == 0 {
+		cfg.Disk.Interval = 60
+	}
+	if cfg.Delivery.QueueSize == 0 {
+		cfg.Delivery.QueueSize = 20
+	}
+	if cfg.Delivery.Workers == 0 {
+		cfg.Delivery.Workers = 1
+	}
+	if cfg.Delivery.NotifyWorkers == 0 {
+		cfg.Delivery.NotifyWorkers = 1
+	}
+}
+
+// addDefaultTarget uploads to ftp section server when no targets are configured
+func addDefaultTarget(cfg *Config) {
+	if len(cfg.Targets) > 0 || cfg.FTP.Host == "" {
+		return
+	}
+	cfg.Targets = []ConfigTarget{{
+		Name:         "ftp",
+		Type:         "ftp",
+		Threshold:    cfg.Settings.UploadThreshold,
+		PathTemplate: "{{.Dir}}/{{.Name}}",
+		Host:         cfg.FTP.Host,
+		Port:         cfg.FTP.Port,
+		User:         cfg.FTP.User,
+		Pass:         cfg.FTP.Pass,
+	}}
+}
+
+// addDefaultNotifier sends all notifications by email when no notifiers are configured
+func addDefaultNotifier(cfg *Config) {
+	if len(cfg.Notifiers) > 0 || cfg.SMTP.Host == "" {
+		return
+	}
+	cfg.Notifiers = []ConfigNotifier{{Name: "email", Type: "email"}}
+}
+
 func validateConfig(cfg *Config) {
 	if cfg.Settings.Sensitivity <= 0.0 || cfg.Settings.Sensitivity > 1.0 {
 		log.Fatal("Sensitivity is out of range 0.0 - 1.0\n")
@@ -166,6 +430,12 @@ func validateConfig(cfg *Config) {
 	if cfg.Settings.EmailInterval < 0 || cfg.Settings.EmailInterval > 3600 {
 		log.Fatal("EmailInterval is out of range 0 - 3600 seconds\n")
 	}
+	if cfg.Settings.TamperThreshold != 0 && (cfg.Settings.TamperThreshold <= cfg.Settings.EmailThreshold || cfg.Settings.TamperThreshold > 1.0) {
+		log.Fatal("TamperThreshold is out of range emailThreshold - 1.0, 0 disables it\n")
+	}
+	if cfg.Settings.EventGap < 0 || cfg.Settings.EventGap > 3600 {
+		log.Fatal("EventGap is out of range 0 - 3600 seconds\n")
+	}
 	if cfg.Settings.ImageDir == "" {
 		log.Fatal("ImageDir must be defined\n")
 	}
@@ -175,4 +445,342 @@ func validateConfig(cfg *Config) {
 	if cfg.Settings.FFmpegCmd == "" {
 		log.Fatal("FFmpegCmd must be defined\n")
 	}
+	validateTargets(cfg.Targets)
+	validateNotifiers(cfg.Notifiers, &cfg.SMTP)
+	for _, t := range cfg.Targets {
+		if t.Manifest != "" && cfg.Settings.ManifestDir == "" {
+			log.Fatal("ManifestDir must be defined when target manifest is used\n")
+		}
+	}
+	validateMQTT(&cfg.MQTT)
+	validateClip(&cfg.Clip)
+	validateMontage(&cfg.Montage)
+	validateTimelapse(&cfg.Timelapse)
+	validateStorage(&cfg.Storage)
+	validateDisk(&cfg.Disk)
+	validateDelivery(&cfg.Delivery)
+	validateEncryption(cfg)
+}
+
+func validateTargets(targets []ConfigTarget) {
+	names := map[string]bool{}
+	for _, t := range targets {
+		if t.Name == "" || names[t.Name] {
+			log.Fatalf("Target name must be defined and unique: %q\n", t.Name)
+		}
+		names[t.Name] = true
+		if t.Threshold < 0.0 || t.Threshold > 1.0 {
+			log.Fatalf("Target %s threshold is out of range 0.0 - 1.0\n", t.Name)
+		}
+		if t.PathTemplate == "" {
+			log.Fatalf("Target %s pathTemplate must be defined\n", t.Name)
+		}
+		switch t.Type {
+		case "ftp":
+			if t.Host == "" {
+				log.Fatalf("Target %s host must be defined\n", t.Name)
+			}
+			if t.TLSMode != "" && t.TLSMode != "none" && t.TLSMode != "explicit" && t.TLSMode != "implicit" {
+				log.Fatalf("Target %s tlsMode must be none, explicit or implicit\n", t.Name)
+			}
+			if fp := strings.ReplaceAll(t.Fingerprint, ":", ""); fp != "" && !isSHA256(fp) {
+				log.Fatalf("Target %s fingerprint must be SHA-256 of server certificate\n", t.Name)
+			}
+			if t.KeepAlive < 0 {
+				log.Fatalf("Target %s keepAlive must not be negative\n", t.Name)
+			}
+		case "sftp":
+			if t.Host == "" || t.User == "" {
+				log.Fatalf("Target %s host and user must be defined\n", t.Name)
+			}
+			if t.KnownHosts == "" {
+				log.Fatalf("Target %s knownHosts must be defined\n", t.Name)
+			}
+			if t.Pass == "" && t.KeyFile == "" {
+				log.Fatalf("Target %s pass or keyFile must be defined\n", t.Name)
+			}
+		case "s3":
+			if t.Endpoint == "" || t.Bucket == "" {
+				log.Fatalf("Target %s endpoint and bucket must be defined\n", t.Name)
+			}
+		case "webdav":
+			if t.Endpoint == "" {
+				log.Fatalf("Target %s endpoint must be defined\n", t.Name)
+			}
+		case "local":
+			if t.Dir == "" {
+				log.Fatalf("Target %s dir must be defined\n", t.Name)
+			}
+		default:
+			log.Fatalf("Target %s type %q is not supported\n", t.Name, t.Type)
+		}
+		if t.Workers < 0 || t.Workers > 10 {
+			log.Fatalf("Target %s workers is out of range 0 - 10\n", t.Name)
+		}
+		validatePrune(t.Name, &t.Prune)
+	}
+}
+
+// isSHA256 reports whether fingerprint is hex encoded SHA-256 sum
+func isSHA256(fingerprint string) bool {
+	sum, err := hex.DecodeString(fingerprint)
+	return err == nil && len(sum) == sha256.Size
+}
+
+func validateNotifiers(notifiers []ConfigNotifier, smtp *ConfigSMTP) {
+	kinds := map[string]bool{"start": true, "alert": true, "event": true, "capture-failure": true,
+		"upload-failure": true, "disk-low": true, "tamper": true, "recovery": true}
+	names := map[string]bool{}
+	for _, n := range notifiers {
+		if n.Name == "" || names[n.Name] {
+			log.Fatalf("Notifier name must be defined and unique: %q\n", n.Name)
+		}
+		names[n.Name] = true
+		for _, k := range n.Kinds {
+			if !kinds[k] {
+				log.Fatalf("Notifier %s kind %q is not supported\n", n.Name, k)
+			}
+		}
+		if n.MinSeverity != "" && n.MinSeverity != "info" && n.MinSeverity != "warning" && n.MinSeverity != "critical" {
+			log.Fatalf("Notifier %s minSeverity must be info, warning or critical\n", n.Name)
+		}
+		if n.RateLimit < 0 {
+			log.Fatalf("Notifier %s rateLimit must not be negative\n", n.Name)
+		}
+		if n.Retries < 0 || n.Retries > 10 {
+			log.Fatalf("Notifier %s retries is out of range 0 - 10\n", n.Name)
+		}
+		if n.Timeout < 0 || n.Timeout > 300 {
+			log.Fatalf("Notifier %s timeout is out of range 0 - 300\n", n.Name)
+		}
+		switch n.Type {
+		case "email":
+			if smtp.Host == "" {
+				log.Fatalf("Notifier %s requires smtp host\n", n.Name)
+			}
+			if len(n.To)+len(n.CC)+len(n.BCC) == 0 && len(smtp.To)+len(smtp.CC)+len(smtp.BCC) == 0 && smtp.Receiver == "" {
+				log.Fatalf("Notifier %s recipients must be defined in notifier or smtp\n", n.Name)
+			}
+		case "webhook":
+			if n.URL == "" {
+				log.Fatalf("Notifier %s url must be defined\n", n.Name)
+			}
+			if n.Image != "" && n.Image != "multipart" && n.Image != "base64" {
+				log.Fatalf("Notifier %s image must be empty, multipart or base64\n", n.Name)
+			}
+		case "slack":
+			if n.Token == "" && n.URL == "" {
+				log.Fatalf("Notifier %s url or token must be defined\n", n.Name)
+			}
+			if n.Token != "" && n.Channel == "" {
+				log.Fatalf("Notifier %s channel must be defined with token\n", n.Name)
+			}
+		case "ntfy":
+			if n.URL == "" || n.Topic == "" {
+				log.Fatalf("Notifier %s url and topic must be defined\n", n.Name)
+			}
+			validatePriorities(n, 1, 5)
+		case "gotify":
+			if n.URL == "" || n.Token == "" {
+				log.Fatalf("Notifier %s url and token must be defined\n", n.Name)
+			}
+			validatePriorities(n, 0, 10)
+		case "discord", "mattermost":
+			if n.URL == "" {
+				log.Fatalf("Notifier %s url must be defined\n", n.Name)
+			}
+		case "telegram":
+			if n.Token == "" || n.ChatID == "" {
+				log.Fatalf("Notifier %s token and chatId must be defined\n", n.Name)
+			}
+			if _, err := strconv.ParseInt(n.ChatID, 10, 64); n.Commands && err != nil && len(n.AllowedChats) == 0 {
+				log.Fatalf("Notifier %s allowedChats must be defined when chatId is not numeric\n", n.Name)
+			}
+		default:
+			log.Fatalf("Notifier %s type %q is not supported\n", n.Name, n.Type)
+		}
+	}
+}
+
+func validatePriorities(n ConfigNotifier, min, max int) {
+	for _, p := range n.Priorities {
+		if p.Score < 0 || p.Score > 1 {
+			log.Fatalf("Notifier %s priority score is out of range 0 - 1\n", n.Name)
+		}
+		if p.Priority < min || p.Priority > max {
+			log.Fatalf("Notifier %s priority is out of range %d - %d\n", n.Name, min, max)
+		}
+	}
+}
+
+func validatePrune(name string, prune *ConfigPrune) {
+	if prune.Interval < 0 || prune.MaxAge < 0 || prune.MaxSize < 0 {
+		log.Fatalf("Target %s prune values must not be negative\n", name)
+	}
+	if prune.Interval > 0 && prune.MaxAge == 0 && prune.MaxSize == 0 {
+		log.Fatalf("Target %s prune maxAge or maxSize must be defined\n", name)
+	}
+	// pruning whole target would remove files that watchdog did not upload
+	if root := path.Clean("/" + prune.Root); prune.Interval > 0 && root == "/" {
+		log.Fatalf("Target %s prune root must be a subdirectory of the target\n", name)
+	}
+}
+
+func validateMQTT(mqtt *ConfigMQTT) {
+	if !mqtt.Enabled {
+		return
+	}
+	if mqtt.Broker == "" {
+		log.Fatal("MQTT broker must be defined\n")
+	}
+	if mqtt.TopicPrefix == "" {
+		log.Fatal("MQTT topicPrefix must be defined\n")
+	}
+	if mqtt.Discovery && mqtt.DiscoveryPrefix == "" {
+		log.Fatal("MQTT discoveryPrefix must be defined\n")
+	}
+	if mqtt.MotionTimeout <= 0 || mqtt.MotionTimeout > 3600 {
+		log.Fatal("MQTT motionTimeout is out of range 1 - 3600 seconds\n")
+	}
+}
+
+func validateClip(clip *ConfigClip) {
+	if !clip.Enabled {
+		return
+	}
+	if clip.Source != "frames" && clip.Source != "rtsp" {
+		log.Fatal("Clip source must be frames or rtsp\n")
+	}
+	if clip.Length <= 0 || clip.Length > 300 {
+		log.Fatal("Clip length is out of range 1 - 300 seconds\n")
+	}
+	if clip.FrameRate <= 0 || clip.FrameRate > 30 {
+		log.Fatal("Clip frameRate is out of range 1 - 30\n")
+	}
+	if clip.Preset == "" {
+		log.Fatal("Clip preset must be defined\n")
+	}
+	if clip.MaxWidth <= 0 {
+		log.Fatal("Clip maxWidth must be positive\n")
+	}
+	if clip.MaxSize < 0 {
+		log.Fatal("Clip maxSize must not be negative\n")
+	}
+	if clip.Source == "frames" && clip.FramesCmd == "" {
+		log.Fatal("Clip framesCmd must be defined\n")
+	}
+	if clip.Source == "rtsp" && clip.RecordCmd == "" {
+		log.Fatal("Clip recordCmd must be defined\n")
+	}
+}
+
+func validateMontage(montage *ConfigMontage) {
+	if !montage.Enabled {
+		return
+	}
+	if montage.Columns <= 0 || montage.Columns > 20 {
+		log.Fatal("Montage columns is out of range 1 - 20\n")
+	}
+	if montage.TileWidth < 64 || montage.TileWidth > 1920 {
+		log.Fatal("Montage tileWidth is out of range 64 - 1920\n")
+	}
+	if montage.MaxTiles <= 0 || montage.MaxTiles > 400 {
+		log.Fatal("Montage maxTiles is out of range 1 - 400\n")
+	}
+}
+
+func validateTimelapse(timelapse *ConfigTimelapse) {
+	if !timelapse.Enabled {
+		return
+	}
+	if timelapse.Hour < 0 || timelapse.Hour > 23 {
+		log.Fatal("Timelapse hour is out of range 0 - 23\n")
+	}
+	if timelapse.Format != "video" && timelapse.Format != "frames" {
+		log.Fatal("Timelapse format must be video or frames\n")
+	}
+	if timelapse.SampleInterval < 0 || timelapse.SampleInterval > 3600 {
+		log.Fatal("Timelapse sampleInterval is out of range 0 - 3600 seconds\n")
+	}
+	if timelapse.IdleInterval < 0 || timelapse.IdleInterval > 86400 {
+		log.Fatal("Timelapse idleInterval is out of range 0 - 86400 seconds\n")
+	}
+	if timelapse.MaxWidth <= 0 {
+		log.Fatal("Timelapse maxWidth must be positive\n")
+	}
+	if timelapse.Format == "video" {
+		if timelapse.FrameRate <= 0 || timelapse.FrameRate > 60 {
+			log.Fatal("Timelapse frameRate is out of range 1 - 60\n")
+		}
+		if timelapse.Preset == "" {
+			log.Fatal("Timelapse preset must be defined\n")
+		}
+		if timelapse.VideoCmd == "" {
+			log.Fatal("Timelapse videoCmd must be defined\n")
+		}
+	}
+}
+
+func validateStorage(storage *ConfigStorage) {
+	// sequence is the only field changing between frames captured within a second
+	first, err := expandPath(storage.PathTemplate, "0001")
+	if err != nil {
+		log.Fatalf("Storage pathTemplate is not valid: %s\n", err)
+	}
+	if second, _ := expandPath(storage.PathTemplate, "0002"); first == second {
+		log.Fatal("Storage pathTemplate must contain {{.Seq}} so that image names are unique\n")
+	}
+	r := storage.Retention
+	if r.Interval <= 0 || r.Interval > 86400 {
+		log.Fatal("Retention interval is out of range 1 - 86400 seconds\n")
+	}
+	if r.MaxAge < 0 || r.AlertMaxAge < 0 || r.MaxSize < 0 || r.MaxFiles < 0 {
+		log.Fatal("Retention limits must not be negative\n")
+	}
+	if r.MaxAge > 0 && r.AlertMaxAge > 0 && r.AlertMaxAge < r.MaxAge {
+		log.Fatal("Retention alertMaxAge must not be shorter than maxAge\n")
+	}
+}
+
+// expandPath expands storage path template the same way as capture does
+func expandPath(pathTemplate, seq string) (string, error) {
+	return pathtmpl.Expand(pathTemplate, time.Now(), struct{ Camera, Seq string }{"camera", seq})
+}
+
+func validateDisk(disk *ConfigDisk) {
+	if disk.Interval <= 0 || disk.Interval > 3600 {
+		log.Fatal("Disk interval is out of range 1 - 3600 seconds\n")
+	}
+	if disk.LowWatermark < 0 {
+		log.Fatal("Disk lowWatermark must not be negative\n")
+	}
+	if disk.HighWatermark < disk.LowWatermark {
+		log.Fatal("Disk highWatermark must not be lower than lowWatermark\n")
+	}
+}
+
+func validateDelivery(delivery *ConfigDelivery) {
+	if delivery.QueueSize <= 0 || delivery.QueueSize > 1000 {
+		log.Fatal("Delivery queueSize is out of range 1 - 1000\n")
+	}
+	if delivery.Workers <= 0 || delivery.Workers > 10 {
+		log.Fatal("Delivery workers is out of range 1 - 10\n")
+	}
+	if delivery.NotifyWorkers <= 0 || delivery.NotifyWorkers > 10 {
+		log.Fatal("Delivery notifyWorkers is out of range 1 - 10\n")
+	}
+}
+
+func validateEncryption(cfg *Config) {
+	e := &cfg.Encryption
+	if !e.Uploads && !e.Local {
+		return
+	}
+	if len(e.Recipients) == 0 && e.RecipientsFile == "" {
+		log.Fatal("Encryption recipients or recipientsFile must be defined\n")
+	}
+	// encrypted frames cannot be read back to build clips, montages or timelapses
+	if e.Local && (cfg.Timelapse.Enabled || cfg.Montage.Enabled || (cfg.Clip.Enabled && cfg.Clip.Source == "frames")) {
+		log.Fatal("Local encryption cannot be combined with timelapse, montage or clips from frames\n")
+	}
 }
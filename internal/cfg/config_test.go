@@ -0,0 +1,103 @@
+package cfg
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestAddDefaults(t *testing.T) {
+	var cfg Config
+	addDefaults(&cfg)
+	if cfg.Settings.IndexFile != "./watchdog.db" || cfg.Settings.QueueFile != "./queue.db" {
+		t.Errorf("unexpected default settings %+v", cfg.Settings)
+	}
+	if cfg.Storage.PathTemplate != "0%w/0%w%H-{{.Seq}}.jpg" || cfg.Storage.Retention != (ConfigRetention{Interval: 600, MaxAge: 168, AlertMaxAge: 168}) {
+		t.Errorf("unexpected default storage %+v", cfg.Storage)
+	}
+	if cfg.Disk != (ConfigDisk{Interval: 60, LowWatermark: 200, HighWatermark: 500}) {
+		t.Errorf("unexpected default disk %+v", cfg.Disk)
+	}
+	if cfg.Delivery != (ConfigDelivery{QueueSize: 20, Workers: 1, NotifyWorkers: 1}) {
+		t.Errorf("unexpected default delivery %+v", cfg.Delivery)
+	}
+
+	cfg = Config{
+		Settings: ConfigSettings{IndexFile: "/var/lib/watchdog/index.db"},
+		Storage:  ConfigStorage{Retention: ConfigRetention{MaxFiles: 1000}},
+	}
+	addDefaults(&cfg)
+	if cfg.Settings.IndexFile != "/var/lib/watchdog/index.db" {
+		t.Errorf("configured indexFile was replaced: %s", cfg.Settings.IndexFile)
+	}
+	if cfg.Storage.Retention != (ConfigRetention{Interval: 600, MaxFiles: 1000}) {
+		t.Errorf("configured retention was replaced: %+v", cfg.Storage.Retention)
+	}
+}
+
+// legacyConfig is configuration file of the first release
+const legacyConfig = `
+camera:
+  host: camera
+  port: 554
+ftp:
+  host:
+smtp:
+  host: smtp.example.com
+  port: 587
+  receiver: me@example.com
+settings:
+  id: garden
+  sensitivity: 0.25
+  keepThreshold: 0.10
+  uploadThreshold: 0.12
+  emailThreshold: 0.16
+  emailInterval: 900
+  imageDir: "./images"
+  logFile: "./log/watchdog.log"
+  ffmpegCmd: "ffmpeg -i rtsp://{{.Host}}:{{.Port}}/stream1 -frames:v 1 {{.Image}} -y"
+`
+
+func TestLoadLegacyConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	if err := ioutil.WriteFile(path, []byte(legacyConfig), 0644); err != nil {
+		t.Fatal(err)
+	}
+	LoadConfig(path)
+	if Settings.IndexFile == "" || Settings.QueueFile == "" || Storage.PathTemplate == "" || Disk.Interval == 0 || Delivery.NotifyWorkers == 0 {
+		t.Errorf("defaults were not applied: %+v %+v %+v %+v", Settings, Storage, Disk, Delivery)
+	}
+	if len(Notifiers) != 1 || Notifiers[0].Type != "email" {
+		t.Errorf("expected default email notifier, got %v", Notifiers)
+	}
+}
+
+func TestExpandPath(t *testing.T) {
+	for _, tc := range []struct {
+		template string
+		valid    bool
+	}{
+		{"{{.Camera}}/%Y/%m/%d/%H%M%S-{{.Seq}}.jpg", true},
+		{"%Y/%Q-{{.Seq}}.jpg", false},
+		{"{{.Day}}/{{.Seq}}.jpg", false},
+		{"{{.Seq.jpg", false},
+	} {
+		if _, err := expandPath(tc.template, "0001"); (err == nil) != tc.valid {
+			t.Errorf("%s: unexpected error %v", tc.template, err)
+		}
+	}
+}
+
+func TestIsSHA256(t *testing.T) {
+	valid := "5E:FF:56:A2:AF:15:88:25:35:D6:58:A1:0C:9F:6C:4A:8B:11:40:9E:4F:8C:12:EF:CC:4F:2B:83:5D:1E:7C:04"
+	if !isSHA256(strings.ReplaceAll(valid, ":", "")) {
+		t.Error("valid fingerprint rejected")
+	}
+	if isSHA256(strings.Repeat("zz", 32)) {
+		t.Error("non-hex fingerprint accepted")
+	}
+	if isSHA256("5eff56") {
+		t.Error("short fingerprint accepted")
+	}
+}
@@ -0,0 +1,196 @@
+package crypt
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// Suffix is appended to names of encrypted uploads
+const Suffix = ".age"
+
+// header starts every age encrypted file
+var header = []byte("age-encryption.org/v1\n")
+
+var recipients []age.Recipient
+
+// Init parses age X25519 recipients from configuration
+func Init() error {
+	recipients = nil
+	c := cfg.Encryption
+	if !c.Uploads && !c.Local {
+		return nil
+	}
+	lines := strings.Join(c.Recipients, "\n")
+	if c.RecipientsFile != "" {
+		data, err := ioutil.ReadFile(c.RecipientsFile)
+		if err != nil {
+			return err
+		}
+		lines += "\n" + string(data)
+	}
+	parsed, err := age.ParseRecipients(strings.NewReader(lines))
+	if err != nil {
+		return err
+	}
+	recipients = parsed
+	return nil
+}
+
+// Uploads reports whether files are encrypted before upload, encrypted local files are always uploaded encrypted
+func Uploads() bool {
+	return len(recipients) > 0 && (cfg.Encryption.Uploads || cfg.Encryption.Local)
+}
+
+// Local reports whether stored images are encrypted once processed
+func Local() bool {
+	return len(recipients) > 0 && cfg.Encryption.Local
+}
+
+// IsEncrypted reports whether file is age encrypted
+func IsEncrypted(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	buf := make([]byte, len(header))
+	_, err = io.ReadFull(f, buf)
+	return err == nil && bytes.Equal(buf, header)
+}
+
+// EncryptTo writes encrypted contents of src to dst, already encrypted file is copied as is
+func EncryptTo(dst io.Writer, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// file is opened once so that concurrent in place encryption cannot be seen half way
+	r := bufio.NewReader(f)
+	if start, _ := r.Peek(len(header)); bytes.Equal(start, header) {
+		_, err = io.Copy(dst, r)
+		return err
+	}
+	w, err := age.Encrypt(dst, recipients...)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// EncryptTemp encrypts src to temporary file which caller removes
+func EncryptTemp(src string) (string, error) {
+	out, err := ioutil.TempFile("", "watchdog-*"+Suffix)
+	if err != nil {
+		return "", err
+	}
+	err = EncryptTo(out, src)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(out.Name())
+		return "", err
+	}
+	return out.Name(), nil
+}
+
+// EncryptFile encrypts file in place keeping its name
+func EncryptFile(path string) error {
+	return replace(path, path, func(w io.Writer) error { return EncryptTo(w, path) })
+}
+
+// DecryptFile decrypts src to dst, dst may be the same file
+func DecryptFile(src, dst string, identities []age.Identity) error {
+	if !IsEncrypted(src) {
+		return fmt.Errorf("%s is not encrypted", src)
+	}
+	return replace(src, dst, func(w io.Writer) error {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r, err := age.Decrypt(f, identities...)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, r)
+		return err
+	})
+}
+
+// LoadIdentities reads age identity file such as one created by age-keygen
+func LoadIdentities(path string) ([]age.Identity, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, errors.New("no identities found")
+	}
+	return identities, nil
+}
+
+// replace writes dst through temporary file in its directory so that it never appears half written,
+// mode and modification time of src are kept so that retention ages files the same way
+func replace(src, dst string, write func(w io.Writer) error) error {
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	out, err := ioutil.TempFile(filepath.Dir(dst), ".crypt-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	if err := write(out); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Chmod(fi.Mode().Perm()); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := os.Chtimes(out.Name(), fi.ModTime(), fi.ModTime()); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), dst)
+}
+
+// EncryptImages encrypts plain images and their JSON sidecars left in directory, such as ones waiting for encryption
+// when watchdog stopped
+func EncryptImages(dir string) error {
+	return filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if fi.IsDir() || (ext != ".jpg" && ext != ".json") || IsEncrypted(path) {
+			return nil
+		}
+		return EncryptFile(path)
+	})
+}
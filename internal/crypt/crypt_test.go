@@ -0,0 +1,70 @@
+package crypt
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+func TestEncryptFile(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Encryption = cfg.ConfigEncryption{Recipients: []string{identity.Recipient().String()}, Local: true}
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !Local() || !Uploads() {
+		t.Fatal("expected local encryption to encrypt uploads too")
+	}
+
+	dir := t.TempDir()
+	image := filepath.Join(dir, "0007-0001.jpg")
+	if err := ioutil.WriteFile(image, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	sidecar := filepath.Join(dir, "0007-0001.json")
+	if err := ioutil.WriteFile(sidecar, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := EncryptImages(dir); err != nil {
+		t.Fatal(err)
+	}
+	encrypted, _ := ioutil.ReadFile(image)
+	if !IsEncrypted(image) || bytes.Contains(encrypted, []byte("jpeg")) {
+		t.Fatalf("image was not encrypted: %q", encrypted)
+	}
+	if !IsEncrypted(sidecar) {
+		t.Error("sidecar was not encrypted")
+	}
+
+	// encrypted file is uploaded as is instead of being encrypted twice
+	upload, err := EncryptTemp(image)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(upload)
+	uploaded, _ := ioutil.ReadFile(upload)
+	if !bytes.Equal(uploaded, encrypted) {
+		t.Error("encrypted file was encrypted again")
+	}
+
+	restored := filepath.Join(dir, "restored.jpg")
+	if err := DecryptFile(upload, restored, []age.Identity{identity}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(restored); string(data) != "jpeg" {
+		t.Errorf("unexpected decrypted data %q", data)
+	}
+
+	other, _ := age.GenerateX25519Identity()
+	if err := DecryptFile(image, image, []age.Identity{other}); err == nil {
+		t.Error("expected decryption with wrong key to fail")
+	}
+}
@@ -1,6 +1,8 @@
 package email
 
 import (
+	"errors"
+	"fmt"
 	"net/smtp"
 	"strconv"
 
@@ -8,17 +10,70 @@ import (
 	"github.com/kornelkabele/watchdog/internal/cfg"
 )
 
-func SendEmail(subj string, body string, attachments []string) error {
+// Message is email with optional HTML body and images embedded inline
+type Message struct {
+	To      []string
+	CC      []string
+	BCC     []string
+	Subject string
+	Text    string
+	HTML    string
+	// Inline images are referenced from HTML as cid:<content id>
+	Inline      []Inline
+	Attachments []string
+}
+
+// Inline is image embedded in HTML body
+type Inline struct {
+	Path string
+	CID  string
+}
+
+// Recipients returns To, CC and BCC lists of SMTP configuration, receiver is added to To
+func Recipients() (to, cc, bcc []string) {
+	to = append(to, cfg.SMTP.To...)
+	if cfg.SMTP.Receiver != "" {
+		to = append(to, cfg.SMTP.Receiver)
+	}
+	return to, cfg.SMTP.CC, cfg.SMTP.BCC
+}
+
+// Send sends message through configured SMTP server
+func Send(m Message) error {
+	e, err := build(m)
+	if err != nil {
+		return err
+	}
+	return e.Send(cfg.SMTP.Host+":"+strconv.Itoa(cfg.SMTP.Port), smtp.PlainAuth("", cfg.SMTP.User, cfg.SMTP.Pass, cfg.SMTP.Host))
+}
+
+func build(m Message) (*email.Email, error) {
+	if len(m.To)+len(m.CC)+len(m.BCC) == 0 {
+		return nil, errors.New("no recipients")
+	}
 	e := email.NewEmail()
 	e.From = cfg.SMTP.Sender
-	e.To = []string{cfg.SMTP.Receiver}
-	e.Subject = subj
-	e.Text = []byte(body)
-	for _, v := range attachments {
-		e.AttachFile(v)
+	e.To = m.To
+	e.Cc = m.CC
+	e.Bcc = m.BCC
+	e.Subject = m.Subject
+	e.Text = []byte(m.Text)
+	e.HTML = []byte(m.HTML)
+	for _, image := range m.Inline {
+		a, err := e.AttachFile(image.Path)
+		if err != nil {
+			return nil, err
+		}
+		// without HTML body there is nothing to embed images into
+		if m.HTML != "" {
+			a.HTMLRelated = true
+			a.Header.Set("Content-ID", fmt.Sprintf("<%s>", image.CID))
+		}
 	}
-	if err := e.Send(cfg.SMTP.Host+":"+strconv.Itoa(cfg.SMTP.Port), smtp.PlainAuth("", cfg.SMTP.User, cfg.SMTP.Pass, cfg.SMTP.Host)); err != nil {
-		return err
+	for _, v := range m.Attachments {
+		if _, err := e.AttachFile(v); err != nil {
+			return nil, err
+		}
 	}
-	return nil
+	return e, nil
 }
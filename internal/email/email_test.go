@@ -0,0 +1,44 @@
+package email
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "alert.jpg")
+	clip := filepath.Join(dir, "clip.mp4")
+	for _, f := range []string{image, clip} {
+		if err := os.WriteFile(f, []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	e, err := build(Message{To: []string{"a@example.com", "b@example.com"}, CC: []string{"c@example.com"}, BCC: []string{"d@example.com"},
+		Subject: "CAMERA ALERT", Text: "alert", HTML: `<img src="cid:image1@watchdog">`,
+		Inline: []Inline{{image, "image1@watchdog"}}, Attachments: []string{clip}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := e.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw := string(data)
+	for _, want := range []string{"To: <a@example.com>, <b@example.com>", "Cc: <c@example.com>", "multipart/related",
+		"Content-Id: <image1@watchdog>", "Content-Disposition: inline", `filename="clip.mp4"`} {
+		if !strings.Contains(raw, want) {
+			t.Fatalf("message does not contain %q:\n%s", want, raw)
+		}
+	}
+	if strings.Contains(raw, "d@example.com") {
+		t.Fatal("bcc recipient must not appear in message")
+	}
+
+	if _, err := build(Message{Subject: "nobody"}); err == nil {
+		t.Fatal("message without recipients should fail")
+	}
+}
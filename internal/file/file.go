@@ -1,9 +1,13 @@
 package file
 
 import (
+	"encoding/hex"
+	"hash"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // CreateDir creates a directory if it does not exist
@@ -17,8 +21,8 @@ func CreateDir(dir string) (err error) {
 	return
 }
 
-// RemoveContents removes directory contents
-func RemoveContents(dir string) {
+// RemoveContents removes directory contents and returns removed paths
+func RemoveContents(dir string) (removed []string) {
 	files, err := filepath.Glob(dir)
 	if err != nil {
 		log.Println(err)
@@ -27,8 +31,11 @@ func RemoveContents(dir string) {
 		err = os.RemoveAll(file)
 		if err != nil {
 			log.Println(err)
+			continue
 		}
+		removed = append(removed, file)
 	}
+	return
 }
 
 // CountFiles counts number of files in directory
@@ -36,3 +43,52 @@ func CountFiles(dir string) (int, error) {
 	files, err := filepath.Glob(dir)
 	return len(files), err
 }
+
+// Checksum returns hex encoded hash of file contents
+func Checksum(path string, h hash.Hash) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Info describes remote file listed by storages that have no native os.FileInfo
+type Info struct {
+	name    string
+	size    int64
+	modTime time.Time
+	dir     bool
+}
+
+// NewInfo creates file description of given name
+func NewInfo(name string, size int64, modTime time.Time, dir bool) *Info {
+	return &Info{name, size, modTime, dir}
+}
+
+// Name returns base name of file
+func (i *Info) Name() string { return i.name }
+
+// Size returns length in bytes
+func (i *Info) Size() int64 { return i.size }
+
+// Mode returns file mode bits
+func (i *Info) Mode() os.FileMode {
+	if i.dir {
+		return os.ModeDir | 0755
+	}
+	return 0644
+}
+
+// ModTime returns modification time
+func (i *Info) ModTime() time.Time { return i.modTime }
+
+// IsDir reports whether file is a directory
+func (i *Info) IsDir() bool { return i.dir }
+
+// Sys returns nil
+func (i *Info) Sys() interface{} { return nil }
@@ -1,48 +1,374 @@
-package utils
+package ftp
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"os"
-	"path/filepath"
+	"path"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/kornelkabele/watchdog/internal/cfg"
 	"github.com/secsy/goftp"
 )
 
-// UploadFTP uploads srt file to ftp destination
-func UploadFTP(src, dst string) error {
+// resumeSize is size of files such as clips whose interrupted uploads are resumed
+const resumeSize = 1024 * 1024
+
+// FTP uploads files to FTP server keeping control connections open between uploads
+type FTP struct {
+	host      string
+	port      int
+	config    goftp.Config
+	keepAlive time.Duration
+
+	mu       sync.Mutex
+	client   *goftp.Client
+	lastUsed time.Time
+	done     chan struct{}
+}
+
+// New creates FTP uploader of given target, implicit TLS is used unless tlsMode says otherwise
+func New(target cfg.ConfigTarget) *FTP {
 	config := goftp.Config{
-		User:               cfg.FTP.User,
-		Password:           cfg.FTP.Pass,
-		ConnectionsPerHost: 10,
+		User:               target.User,
+		Password:           target.Pass,
+		ConnectionsPerHost: 2,
 		Timeout:            10 * time.Second,
-		TLSMode:            goftp.TLSImplicit,
+		ActiveTransfers:    target.Active,
+	}
+	switch target.TLSMode {
+	case "none":
+	case "explicit":
+		config.TLSMode = goftp.TLSExplicit
+		config.TLSConfig = tlsConfig(target)
+	default:
+		config.TLSMode = goftp.TLSImplicit
+		config.TLSConfig = tlsConfig(target)
+	}
+	return &FTP{
+		host:      target.Host,
+		port:      target.Port,
+		config:    config,
+		keepAlive: time.Duration(target.KeepAlive) * time.Second,
+	}
+}
+
+// tlsConfig verifies server certificate against system roots or pinned fingerprint
+func tlsConfig(target cfg.ConfigTarget) *tls.Config {
+	config := &tls.Config{
+		ServerName:         target.Host,
+		InsecureSkipVerify: target.Insecure,
+		// data connections resume control connection session as many servers require
+		ClientSessionCache: tls.NewLRUClientSessionCache(8),
+	}
+	if target.Fingerprint != "" {
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = verifyFingerprint(target.Fingerprint)
+	}
+	return config
+}
+
+// verifyFingerprint accepts only server certificate with given SHA-256 fingerprint, colons are optional
+func verifyFingerprint(fingerprint string) func([][]byte, [][]*x509.Certificate) error {
+	pinned, _ := hex.DecodeString(strings.ReplaceAll(fingerprint, ":", ""))
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server sent no certificate")
+		}
+		sum := sha256.Sum256(rawCerts[0])
+		if !bytes.Equal(sum[:], pinned) {
+			return fmt.Errorf("server certificate fingerprint %s does not match", hex.EncodeToString(sum[:]))
+		}
+		return nil
 	}
+}
 
-	f, err := os.Open(src)
+// Upload uploads src file to dst path on FTP server, upload failed on broken connection is retried once on fresh one
+func (f *FTP) Upload(src, dst string) error {
+	return f.retry(src, dst, true)
+}
+
+// UploadWhole uploads src file like Upload but never resumes part left by earlier upload
+func (f *FTP) UploadWhole(src, dst string) error {
+	return f.retry(src, dst, false)
+}
+
+func (f *FTP) retry(src, dst string, resume bool) error {
+	err := f.upload(src, dst, resume)
+	if err == nil || !isConnError(err) {
+		return err
+	}
+	f.reset()
+	return f.upload(src, dst, resume)
+}
+
+// isConnError reports whether upload failed on connection rather than by server reply such as denied login or permission
+func isConnError(err error) bool {
+	var fe goftp.Error
+	return errors.As(err, &fe) && fe.Code() == 0
+}
+
+func (f *FTP) upload(src, dst string, resume bool) error {
+	client, err := f.connect()
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	client, err := goftp.DialConfig(config, cfg.FTP.Host)
+	file, err := os.Open(src)
 	if err != nil {
 		return err
 	}
-	defer client.Close()
+	defer file.Close()
 
-	_, err = client.Stat(dst)
+	err = mkdirAll(client, path.Dir(dst))
 	if err != nil {
-		_, err := client.Mkdir(dst)
-		if err != nil {
-			return err
+		return err
+	}
+
+	dst = "/" + strings.TrimPrefix(dst, "/")
+	fi, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < resumeSize {
+		// Store resumes transfer interrupted within this call and checks remote size when server supports it
+		return client.Store(dst, file)
+	}
+
+	// large file is uploaded under temporary name so that only part left by this uploader is resumed
+	part := dst + ".part"
+	if offset := partialSize(client, part, fi.Size()); resume && offset > 0 {
+		err = f.resume(client, file, part, offset)
+	} else {
+		err = client.Store(part, file)
+	}
+	if err != nil {
+		return err
+	}
+	if err := client.Rename(part, dst); err != nil {
+		// some servers do not replace existing file on rename
+		client.Delete(dst)
+		return client.Rename(part, dst)
+	}
+	return nil
+}
+
+// partialSize returns size of part left on server by earlier interrupted upload of file of given size
+func partialSize(client *goftp.Client, part string, size int64) int64 {
+	remote, err := client.Stat(part)
+	if err != nil || remote.Size() >= size {
+		return 0
+	}
+	return remote.Size()
+}
+
+// resume appends rest of file to partially uploaded remote file using REST command
+func (f *FTP) resume(client *goftp.Client, file *os.File, dst string, offset int64) error {
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	conn, err := client.OpenRawConn()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if code, msg, err := conn.SendCommand("TYPE I"); err != nil || code != 200 {
+		return rawError("TYPE I", code, msg, err)
+	}
+	getConn, err := conn.PrepareDataConn()
+	if err != nil {
+		return err
+	}
+	if code, msg, err := conn.SendCommand("REST %d", offset); err != nil || code != 350 {
+		return rawError("REST", code, msg, err)
+	}
+	if code, msg, err := conn.SendCommand("STOR %s", dst); err != nil || code/100 != 1 {
+		return rawError("STOR", code, msg, err)
+	}
+	data, err := getConn()
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(data, file); err != nil {
+		data.Close()
+		return err
+	}
+	if err := data.Close(); err != nil {
+		return err
+	}
+	if code, msg, err := conn.ReadResponse(); err != nil || code/100 != 2 {
+		return rawError("STOR", code, msg, err)
+	}
+	return nil
+}
+
+func rawError(command string, code int, msg string, err error) error {
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%s failed: %d %s", command, code, msg)
+}
+
+// Size returns size of uploaded file
+func (f *FTP) Size(dst string) (int64, error) {
+	client, err := f.connect()
+	if err != nil {
+		return 0, err
+	}
+	fi, err := client.Stat("/" + strings.TrimPrefix(dst, "/"))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// URL returns location of uploaded file
+func (f *FTP) URL(dst string) string {
+	return fmt.Sprintf("ftp://%s/%s", f.address(), strings.TrimPrefix(dst, "/"))
+}
+
+// Close closes all FTP connections
+func (f *FTP) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.close()
+}
+
+// reset closes connections so that next upload dials again
+func (f *FTP) reset() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.close()
+}
+
+func (f *FTP) close() error {
+	if f.client == nil {
+		return nil
+	}
+	if f.done != nil {
+		close(f.done)
+		f.done = nil
+	}
+	err := f.client.Close()
+	f.client = nil
+	return err
+}
+
+// connect returns shared client, connections are opened on demand by the client pool
+func (f *FTP) connect() (*goftp.Client, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.lastUsed = time.Now()
+	if f.client != nil {
+		return f.client, nil
+	}
+	client, err := goftp.DialConfig(f.config, f.address())
+	if err != nil {
+		return nil, err
+	}
+	f.client = client
+	if f.keepAlive > 0 {
+		f.done = make(chan struct{})
+		go f.keepConnAlive(client, f.done)
+	}
+	return client, nil
+}
+
+// keepConnAlive sends a command over idle connection so that server and NAT do not drop it
+func (f *FTP) keepConnAlive(client *goftp.Client, done chan struct{}) {
+	ticker := time.NewTicker(f.keepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			f.mu.Lock()
+			idle := time.Since(f.lastUsed) >= f.keepAlive
+			f.mu.Unlock()
+			if idle {
+				// errors mark the connection broken, it is replaced on next upload
+				client.Getwd()
+			}
 		}
 	}
+}
+
+// Walk calls fn for every file and directory below root
+func (f *FTP) Walk(root string, fn func(path string, info os.FileInfo) error) error {
+	client, err := f.connect()
+	if err != nil {
+		return err
+	}
+	return walk(client, "/"+strings.Trim(root, "/"), fn)
+}
+
+// Remove removes file or empty directory
+func (f *FTP) Remove(dst string, dir bool) error {
+	client, err := f.connect()
+	if err != nil {
+		return err
+	}
+	dst = "/" + strings.TrimPrefix(dst, "/")
+	if dir {
+		return client.Rmdir(dst)
+	}
+	return client.Delete(dst)
+}
 
-	target := "/" + dst + "/" + filepath.Base(src)
-	err = client.Store(target, f)
+func walk(client *goftp.Client, dir string, fn func(path string, info os.FileInfo) error) error {
+	infos, err := client.ReadDir(dir)
 	if err != nil {
 		return err
 	}
+	for _, info := range infos {
+		p := path.Join(dir, info.Name())
+		if err := fn(strings.TrimPrefix(p, "/"), info); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if err := walk(client, p, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (f *FTP) address() string {
+	port := f.port
+	if port == 0 && f.config.TLSConfig != nil && f.config.TLSMode == goftp.TLSImplicit {
+		port = 990
+	}
+	if port == 0 {
+		return f.host
+	}
+	return fmt.Sprintf("%s:%d", f.host, port)
+}
+
+// mkdirAll creates remote directory including missing parents
+func mkdirAll(client *goftp.Client, dir string) error {
+	path := ""
+	for _, part := range strings.Split(dir, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		path += "/" + part
+		if _, err := client.Stat(path); err == nil {
+			continue
+		}
+		if _, err := client.Mkdir(path); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,155 @@
+package ftp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/ftp/ftptest"
+)
+
+// newServer starts test server and returns uploader of plain FTP target pointing to it
+func newServer(t *testing.T, pass string) (*ftptest.Server, *FTP) {
+	server, err := ftptest.NewServer(t.TempDir(), "camera", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	f := New(cfg.ConfigTarget{Host: server.Host(), Port: server.Port(), User: "camera", Pass: pass, TLSMode: "none"})
+	t.Cleanup(func() { f.Close() })
+	return server, f
+}
+
+func TestUploadRetry(t *testing.T) {
+	server, f := newServer(t, "secret")
+	src := filepath.Join(t.TempDir(), "a.jpg")
+	if err := ioutil.WriteFile(src, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// connection dropped during upload is replaced by a fresh one
+	server.Interrupt(0)
+	if err := f.Upload(src, "garden/a.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(server.Root, "garden", "a.jpg"))
+	if err != nil || string(data) != "jpeg" {
+		t.Fatalf("unexpected uploaded file %q, %v", data, err)
+	}
+	if stored := server.Stored(); len(stored) != 2 {
+		t.Errorf("expected upload retried once, got %v", stored)
+	}
+}
+
+func TestUploadDenied(t *testing.T) {
+	server, f := newServer(t, "wrong")
+	src := filepath.Join(t.TempDir(), "a.jpg")
+	if err := ioutil.WriteFile(src, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := f.Upload(src, "a.jpg")
+	if err == nil || isConnError(err) {
+		t.Fatalf("expected failed login, got %v", err)
+	}
+	if stored := server.Stored(); len(stored) != 0 {
+		t.Errorf("unexpected uploads %v", stored)
+	}
+}
+
+func TestUploadResume(t *testing.T) {
+	server, f := newServer(t, "secret")
+	clip := bytes.Repeat([]byte("0123456789abcdef"), resumeSize/8)
+	src := filepath.Join(t.TempDir(), "clip.mp4")
+	if err := ioutil.WriteFile(src, clip, 0644); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(server.Root, "garden")
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// smaller file of the same name was not left by this uploader and is replaced
+	if err := ioutil.WriteFile(filepath.Join(dir, "clip.mp4"), []byte("other"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Upload(src, "garden/clip.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	checkUploaded(t, filepath.Join(dir, "clip.mp4"), clip)
+
+	// part left by interrupted upload is resumed
+	if err := ioutil.WriteFile(filepath.Join(dir, "next.mp4.part"), clip[:resumeSize], 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Upload(src, "garden/next.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	checkUploaded(t, filepath.Join(dir, "next.mp4"), clip)
+	if _, err := os.Stat(filepath.Join(dir, "next.mp4.part")); !os.IsNotExist(err) {
+		t.Errorf("part was not renamed: %v", err)
+	}
+
+	want := []string{"/garden/clip.mp4.part", fmt.Sprintf("/garden/next.mp4.part@%d", resumeSize)}
+	if stored := server.Stored(); strings.Join(stored, " ") != strings.Join(want, " ") {
+		t.Errorf("got uploads %v, want %v", stored, want)
+	}
+}
+
+func checkUploaded(t *testing.T, name string, want []byte) {
+	t.Helper()
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(data, want) {
+		t.Errorf("uploaded %s differs from local file", name)
+	}
+}
+
+func TestVerifyFingerprint(t *testing.T) {
+	server := httptest.NewTLSServer(nil)
+	defer server.Close()
+	raw := server.Certificate().Raw
+	sum := sha256.Sum256(raw)
+
+	colons := strings.ToUpper(hex.EncodeToString(sum[:]))
+	var parts []string
+	for i := 0; i < len(colons); i += 2 {
+		parts = append(parts, colons[i:i+2])
+	}
+	if err := verifyFingerprint(strings.Join(parts, ":"))([][]byte{raw}, nil); err != nil {
+		t.Errorf("pinned certificate rejected: %s", err)
+	}
+
+	other := strings.Repeat("00", sha256.Size)
+	if err := verifyFingerprint(other)([][]byte{raw}, nil); err == nil {
+		t.Error("certificate with different fingerprint accepted")
+	}
+}
+
+func TestAddress(t *testing.T) {
+	tests := []struct {
+		mode string
+		port int
+		want string
+	}{
+		{"", 0, "nas:990"},
+		{"implicit", 2990, "nas:2990"},
+		{"explicit", 0, "nas"},
+		{"none", 21, "nas:21"},
+	}
+	for _, tt := range tests {
+		f := New(cfg.ConfigTarget{Host: "nas", Port: tt.port, TLSMode: tt.mode})
+		if got := f.address(); got != tt.want {
+			t.Errorf("address of %q mode: got %s, want %s", tt.mode, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,279 @@
+// Package ftptest provides plain FTP server storing files in local directory for tests of FTP uploads
+package ftptest
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server is FTP server supporting passive uploads, sizes, renames and directories
+type Server struct {
+	Root string
+	User string
+	Pass string
+
+	listener net.Listener
+	mu       sync.Mutex
+	cut      []int64
+	stored   []string
+}
+
+// NewServer starts server on loopback interface storing files below root, only given user may log in
+func NewServer(root, user, pass string) (*Server, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	s := &Server{Root: root, User: user, Pass: pass, listener: l}
+	go s.serve()
+	return s, nil
+}
+
+// Host returns address of server
+func (s *Server) Host() string {
+	return s.listener.Addr().(*net.TCPAddr).IP.String()
+}
+
+// Port returns port of server
+func (s *Server) Port() int {
+	return s.listener.Addr().(*net.TCPAddr).Port
+}
+
+// Close stops accepting connections
+func (s *Server) Close() error {
+	return s.listener.Close()
+}
+
+// Interrupt drops connections of following uploads after given number of bytes was stored,
+// one upload is interrupted for each value
+func (s *Server) Interrupt(after ...int64) {
+	s.mu.Lock()
+	s.cut = append(s.cut, after...)
+	s.mu.Unlock()
+}
+
+// Stored returns STOR commands received so far as "path" or "path@offset" for resumed uploads
+func (s *Server) Stored() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.stored...)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+// session is state of a single control connection
+type session struct {
+	s      *Server
+	conn   net.Conn
+	r      *bufio.Reader
+	user   string
+	login  bool
+	rest   int64
+	rename string
+	data   net.Listener
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	c := &session{s: s, conn: conn, r: bufio.NewReader(conn)}
+	defer c.closeData()
+	c.reply(220, "ready")
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd, arg := strings.TrimSpace(line), ""
+		if i := strings.IndexByte(cmd, ' '); i >= 0 {
+			cmd, arg = cmd[:i], cmd[i+1:]
+		}
+		if !c.command(strings.ToUpper(cmd), arg) {
+			return
+		}
+	}
+}
+
+func (c *session) reply(code int, msg string) {
+	fmt.Fprintf(c.conn, "%d %s\r\n", code, msg)
+}
+
+// command handles single command, false closes the connection
+func (c *session) command(cmd, arg string) bool {
+	switch cmd {
+	case "USER":
+		c.user = arg
+		c.reply(331, "password required")
+		return true
+	case "PASS":
+		if c.user != c.s.User || arg != c.s.Pass {
+			c.reply(530, "login incorrect")
+			return true
+		}
+		c.login = true
+		c.reply(230, "logged in")
+		return true
+	case "QUIT":
+		c.reply(221, "bye")
+		return false
+	case "FEAT":
+		fmt.Fprint(c.conn, "211-features\r\n SIZE\r\n MLST type*;size*;modify*;\r\n211 end\r\n")
+		return true
+	}
+	if !c.login {
+		c.reply(530, "not logged in")
+		return true
+	}
+
+	name := c.path(arg)
+	switch cmd {
+	case "TYPE":
+		c.reply(200, "type set")
+	case "PWD":
+		c.reply(257, `"/"`)
+	case "EPSV":
+		c.closeData()
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			c.reply(425, err.Error())
+			break
+		}
+		c.data = l
+		c.reply(229, fmt.Sprintf("entering extended passive mode (|||%d|)", l.Addr().(*net.TCPAddr).Port))
+	case "REST":
+		offset, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			c.reply(501, "bad offset")
+			break
+		}
+		c.rest = offset
+		c.reply(350, "restarting")
+	case "STOR":
+		return c.store(arg, name)
+	case "SIZE":
+		fi, err := os.Stat(name)
+		if err != nil || fi.IsDir() {
+			c.reply(550, "no such file")
+			break
+		}
+		c.reply(213, strconv.FormatInt(fi.Size(), 10))
+	case "MLST":
+		fi, err := os.Stat(name)
+		if err != nil {
+			c.reply(550, "no such file")
+			break
+		}
+		typ := "file"
+		if fi.IsDir() {
+			typ = "dir"
+		}
+		fmt.Fprintf(c.conn, "250-listing\r\n type=%s;size=%d;modify=%s; %s\r\n250 end\r\n",
+			typ, fi.Size(), fi.ModTime().UTC().Format("20060102150405"), arg)
+	case "MKD":
+		if err := os.Mkdir(name, 0755); err != nil {
+			c.reply(550, err.Error())
+			break
+		}
+		c.reply(257, fmt.Sprintf("%q created", arg))
+	case "RNFR":
+		c.rename = name
+		c.reply(350, "ready for destination")
+	case "RNTO":
+		if err := os.Rename(c.rename, name); err != nil {
+			c.reply(550, err.Error())
+			break
+		}
+		c.reply(250, "renamed")
+	case "DELE", "RMD":
+		if err := os.Remove(name); err != nil {
+			c.reply(550, err.Error())
+			break
+		}
+		c.reply(250, "removed")
+	default:
+		c.reply(502, "not implemented")
+	}
+	return true
+}
+
+// store receives file over data connection, interrupted upload keeps received part and drops the connection
+func (c *session) store(arg, name string) bool {
+	offset := c.rest
+	c.rest = 0
+	if c.data == nil {
+		c.reply(425, "use EPSV first")
+		return true
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if offset > 0 {
+		flags = os.O_WRONLY
+	}
+	f, err := os.OpenFile(name, flags, 0644)
+	if err != nil {
+		c.reply(550, err.Error())
+		return true
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		c.reply(550, err.Error())
+		return true
+	}
+
+	c.s.mu.Lock()
+	record := arg
+	if offset > 0 {
+		record = fmt.Sprintf("%s@%d", arg, offset)
+	}
+	c.s.stored = append(c.s.stored, record)
+	cut := int64(-1)
+	if len(c.s.cut) > 0 {
+		cut, c.s.cut = c.s.cut[0], c.s.cut[1:]
+	}
+	c.s.mu.Unlock()
+
+	c.reply(150, "opening data connection")
+	data, err := c.data.Accept()
+	c.closeData()
+	if err != nil {
+		c.reply(425, err.Error())
+		return true
+	}
+	defer data.Close()
+	if cut >= 0 {
+		io.CopyN(f, data, cut)
+		return false
+	}
+	if _, err := io.Copy(f, data); err != nil {
+		c.reply(426, err.Error())
+		return true
+	}
+	c.reply(226, "transfer complete")
+	return true
+}
+
+func (c *session) closeData() {
+	if c.data != nil {
+		c.data.Close()
+		c.data = nil
+	}
+}
+
+// path maps absolute remote path to file below root
+func (c *session) path(arg string) string {
+	return filepath.Join(c.s.Root, filepath.FromSlash(path.Clean("/"+arg)))
+}
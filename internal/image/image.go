@@ -11,6 +11,12 @@ import (
 	"github.com/disintegration/imaging"
 )
 
+// Method names the similarity index algorithm
+const Method = "blurred-luma-mse"
+
+// BlurSigma is gaussian blur applied to both images before comparison
+const BlurSigma = 3.5
+
 var maxProcs int64
 
 // SetMaxProcs limits the number of concurrent processing goroutines to the given value.
@@ -63,12 +69,12 @@ func ImageSimilarityIndexFile(origin, reference string, sensitivity float32) (fl
 	if err != nil {
 		return 0, fmt.Errorf("Error opening image file: %s", origin)
 	}
-	img = imaging.Blur(img, 3.5)
+	img = imaging.Blur(img, BlurSigma)
 	ref, err := imaging.Open(reference)
 	if err != nil {
 		return 0, fmt.Errorf("Error opening reference image file: %s", reference)
 	}
-	ref = imaging.Blur(ref, 3.5)
+	ref = imaging.Blur(ref, BlurSigma)
 	return ImageSimilarityIndex(img, ref, sensitivity)
 }
 
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// Tile is a labeled image placed into montage grid
+type Tile struct {
+	Path  string
+	Label string
+}
+
+// Montage tiles images into a single grid image with each tile labeled at its bottom.
+func Montage(tiles []Tile, columns, tileWidth int) (image.Image, error) {
+	if len(tiles) == 0 {
+		return nil, fmt.Errorf("No tiles for montage")
+	}
+	if columns > len(tiles) {
+		columns = len(tiles)
+	}
+	rows := (len(tiles) + columns - 1) / columns
+
+	images := make([]image.Image, len(tiles))
+	tileHeight := 0
+	for i, t := range tiles {
+		img, err := imaging.Open(t.Path)
+		if err != nil {
+			return nil, fmt.Errorf("Error opening image file: %s", t.Path)
+		}
+		images[i] = imaging.Resize(img, tileWidth, 0, imaging.Box)
+		if h := images[i].Bounds().Dy(); h > tileHeight {
+			tileHeight = h
+		}
+	}
+
+	dst := imaging.New(columns*tileWidth, rows*tileHeight, color.Black)
+	for i, img := range images {
+		x, y := (i%columns)*tileWidth, (i/columns)*tileHeight
+		dst = imaging.Paste(dst, img, image.Pt(x, y))
+		drawLabel(dst, tiles[i].Label, x, y+img.Bounds().Dy())
+	}
+	return dst, nil
+}
+
+// drawLabel writes white text on a dark bar ending at baseline y
+func drawLabel(dst draw.Image, label string, x, y int) {
+	face := basicfont.Face7x13
+	bar := image.Rect(x, y-face.Height-4, x+font.MeasureString(face, label).Ceil()+6, y)
+	draw.Draw(dst, bar, image.NewUniform(color.RGBA{0, 0, 0, 160}), image.Point{}, draw.Over)
+
+	d := &font.Drawer{
+		Dst:  dst,
+		Src:  image.White,
+		Face: face,
+		Dot:  fixed.P(x+3, y-face.Descent-2),
+	}
+	d.DrawString(label)
+}
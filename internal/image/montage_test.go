@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"image/color"
+	"path/filepath"
+	"testing"
+
+	"github.com/disintegration/imaging"
+)
+
+// writeImage saves image of given size filled with color
+func writeImage(t *testing.T, name string, width, height int, c color.Color) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := imaging.Save(imaging.New(width, height, c), path); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestMontage(t *testing.T) {
+	red := color.NRGBA{255, 0, 0, 255}
+	blue := color.NRGBA{0, 0, 255, 255}
+	tiles := []Tile{
+		{Path: writeImage(t, "a.png", 80, 60, red), Label: "07:00:01 0.30"},
+		{Path: writeImage(t, "b.png", 80, 60, blue), Label: "07:00:02 0.40"},
+		{Path: writeImage(t, "c.png", 80, 60, red), Label: "07:00:03 0.50"},
+	}
+	sheet, err := Montage(tiles, 2, 40)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := sheet.Bounds(); b.Dx() != 80 || b.Dy() != 60 {
+		t.Fatalf("expected 2x2 grid of 40x30 tiles, got %v", b)
+	}
+
+	// tiles fill grid row by row, label covers bottom of each tile, empty cell stays black
+	tests := []struct {
+		x, y int
+		want color.NRGBA
+	}{
+		{20, 2, red},
+		{60, 2, blue},
+		{20, 32, red},
+		{60, 45, color.NRGBA{0, 0, 0, 255}},
+	}
+	for _, tt := range tests {
+		if got := color.NRGBAModel.Convert(sheet.At(tt.x, tt.y)).(color.NRGBA); got != tt.want {
+			t.Errorf("pixel %d,%d = %v, want %v", tt.x, tt.y, got, tt.want)
+		}
+	}
+	if got := color.NRGBAModel.Convert(sheet.At(1, 28)).(color.NRGBA); got == red {
+		t.Error("label bar is missing")
+	}
+
+	if _, err := Montage(nil, 2, 40); err == nil {
+		t.Error("montage without tiles should fail")
+	}
+	if _, err := Montage([]Tile{{Path: "missing.jpg"}}, 2, 40); err == nil {
+		t.Error("montage of missing image should fail")
+	}
+}
@@ -0,0 +1,325 @@
+package index
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	bolt "go.etcd.io/bbolt"
+)
+
+// Upload and email status values
+const (
+	StatusNone   = ""
+	StatusOK     = "ok"
+	StatusFailed = "failed"
+)
+
+var (
+	framesBucket = []byte("frames")
+	pathsBucket  = []byte("paths")
+	eventsBucket = []byte("events")
+)
+
+// Frame is a kept image record
+type Frame struct {
+	Camera  string    `json:"camera"`
+	Path    string    `json:"path"`
+	Time    time.Time `json:"time"`
+	Score   float32   `json:"score"`
+	Alert   bool      `json:"alert,omitempty"`
+	Event   string    `json:"event,omitempty"`
+	Actions []string  `json:"actions,omitempty"`
+	Upload  string    `json:"upload,omitempty"`
+	Email   string    `json:"email,omitempty"`
+}
+
+// Event is a record of frames grouped by scene change
+type Event struct {
+	ID       string    `json:"id"`
+	Camera   string    `json:"camera"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+	Frames   int       `json:"frames"`
+	MaxScore float32   `json:"maxScore"`
+	Actions  []string  `json:"actions,omitempty"`
+	Upload   string    `json:"upload,omitempty"`
+	Email    string    `json:"email,omitempty"`
+}
+
+// Filter selects records returned by Frames and Events
+type Filter struct {
+	Camera   string
+	Since    time.Time
+	MinScore float32
+}
+
+// EventID returns identifier of event started at given time
+func EventID(start time.Time) string {
+	return start.Format("20060102-150405")
+}
+
+// timeKey orders records by time, path makes frames captured at the same time unique
+func timeKey(t time.Time, suffix string) []byte {
+	return []byte(t.UTC().Format("20060102T150405.000000000") + "|" + suffix)
+}
+
+// flushDelay is how long writes are collected before index is opened to store them
+const flushDelay = time.Second
+
+// Index is opened only for a batch of writes or a single read, so that CLI queries can run alongside capture
+var (
+	mu      sync.Mutex
+	opened  bool
+	pending []func(tx *bolt.Tx) error
+	flusher *time.Timer
+)
+
+// Init creates index buckets and accepts writes until Close
+func Init() error {
+	mu.Lock()
+	defer mu.Unlock()
+	d, err := bolt.Open(cfg.Settings.IndexFile, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("cannot open index: %s", err)
+	}
+	defer d.Close()
+	err = d.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{framesBucket, pathsBucket, eventsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot open index: %s", err)
+	}
+	opened = true
+	return nil
+}
+
+// Close stores pending writes, later writes fail
+func Close() error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !opened {
+		return nil
+	}
+	opened = false
+	return flush()
+}
+
+// update queues write transaction, writes collected within flushDelay are stored together
+func update(fn func(tx *bolt.Tx) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !opened {
+		return errors.New("index is not open")
+	}
+	pending = append(pending, fn)
+	if flusher == nil {
+		flusher = time.AfterFunc(flushDelay, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if err := flush(); err != nil {
+				log.Printf("Failed to write index: %s\n", err)
+			}
+		})
+	}
+	return nil
+}
+
+// flush opens index and stores pending writes, failed write is logged and does not discard the others
+func flush() error {
+	if flusher != nil {
+		flusher.Stop()
+		flusher = nil
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	writes := pending
+	pending = nil
+	d, err := bolt.Open(cfg.Settings.IndexFile, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("cannot open index: %s", err)
+	}
+	defer d.Close()
+	return d.Update(func(tx *bolt.Tx) error {
+		for _, fn := range writes {
+			if err := fn(tx); err != nil {
+				log.Printf("Failed to write index: %s\n", err)
+			}
+		}
+		return nil
+	})
+}
+
+// view runs read transaction on index opened read-only after pending writes are stored,
+// missing index has no records
+func view(fn func(tx *bolt.Tx) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if err := flush(); err != nil {
+		return err
+	}
+	if _, err := os.Stat(cfg.Settings.IndexFile); os.IsNotExist(err) {
+		return nil
+	}
+	d, err := bolt.Open(cfg.Settings.IndexFile, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err == bolt.ErrTimeout {
+		return errors.New("cannot open index: it is locked by another process")
+	}
+	if err != nil {
+		return fmt.Errorf("cannot open index: %s", err)
+	}
+	defer d.Close()
+	return d.View(fn)
+}
+
+// AddFrame stores kept frame record
+func AddFrame(f Frame) error {
+	return update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		key := timeKey(f.Time, f.Path)
+		if err := tx.Bucket(framesBucket).Put(key, data); err != nil {
+			return err
+		}
+		return tx.Bucket(pathsBucket).Put([]byte(f.Path), key)
+	})
+}
+
+// PutEvent stores or replaces event record
+func PutEvent(e Event) error {
+	return update(func(tx *bolt.Tx) error {
+		data, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(eventsBucket).Put([]byte(e.ID), data)
+	})
+}
+
+// RemoveFrames deletes records of removed image files and records of events left without frames
+func RemoveFrames(paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+	return update(func(tx *bolt.Tx) error {
+		frames, byPath := tx.Bucket(framesBucket), tx.Bucket(pathsBucket)
+		events := map[string]bool{}
+		for _, path := range paths {
+			key := byPath.Get([]byte(path))
+			if key == nil {
+				continue
+			}
+			var f Frame
+			if err := json.Unmarshal(frames.Get(key), &f); err == nil && f.Event != "" {
+				events[f.Event] = true
+			}
+			if err := frames.Delete(key); err != nil {
+				return err
+			}
+			if err := byPath.Delete([]byte(path)); err != nil {
+				return err
+			}
+		}
+		if len(events) == 0 {
+			return nil
+		}
+		err := frames.ForEach(func(_, v []byte) error {
+			var f Frame
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			delete(events, f.Event)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for id := range events {
+			if err := tx.Bucket(eventsBucket).Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Frames returns frame records matching filter ordered by time
+func Frames(filter Filter) ([]Frame, error) {
+	var frames []Frame
+	err := view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(framesBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek(timeKey(filter.Since, "")); k != nil; k, v = c.Next() {
+			var f Frame
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			if (filter.Camera == "" || f.Camera == filter.Camera) && f.Score >= filter.MinScore {
+				frames = append(frames, f)
+			}
+		}
+		return nil
+	})
+	return frames, err
+}
+
+// Events returns event records matching filter ordered by start time
+func Events(filter Filter) ([]Event, error) {
+	var events []Event
+	err := view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(eventsBucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(EventID(filter.Since))); k != nil; k, v = c.Next() {
+			var e Event
+			if err := json.Unmarshal(v, &e); err != nil {
+				return err
+			}
+			if (filter.Camera == "" || e.Camera == filter.Camera) && e.MaxScore >= filter.MinScore {
+				events = append(events, e)
+			}
+		}
+		return nil
+	})
+	return events, err
+}
+
+// AlertPaths returns set of image paths recorded as alerts
+func AlertPaths() (map[string]bool, error) {
+	alerts := map[string]bool{}
+	err := view(func(tx *bolt.Tx) error {
+		b := tx.Bucket(framesBucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var f Frame
+			if err := json.Unmarshal(v, &f); err != nil {
+				return err
+			}
+			if f.Alert {
+				alerts[f.Path] = true
+			}
+			return nil
+		})
+	})
+	return alerts, err
+}
@@ -0,0 +1,151 @@
+package index
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	bolt "go.etcd.io/bbolt"
+)
+
+// open opens index in temporary directory for duration of test
+func open(t *testing.T) {
+	cfg.Settings.IndexFile = filepath.Join(t.TempDir(), "index.db")
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { Close() })
+}
+
+func TestFrames(t *testing.T) {
+	open(t)
+
+	now := time.Now()
+	frames := []Frame{
+		{Camera: "garden", Path: "images/a.jpg", Time: now.Add(-48 * time.Hour), Score: 0.5},
+		{Camera: "garden", Path: "images/b.jpg", Time: now.Add(-2 * time.Hour), Score: 0.1},
+		{Camera: "garden", Path: "images/c.jpg", Time: now.Add(-1 * time.Hour), Score: 0.3},
+		{Camera: "door", Path: "images/d.jpg", Time: now.Add(-1 * time.Hour), Score: 0.4},
+	}
+	for _, f := range frames {
+		if err := AddFrame(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Frames(Filter{Camera: "garden", Since: now.Add(-24 * time.Hour), MinScore: 0.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].Path != "images/c.jpg" {
+		t.Errorf("unexpected frames %v", result)
+	}
+
+	if err := RemoveFrames([]string{"images/c.jpg", "images/missing.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	result, err = Frames(Filter{Since: now.Add(-24 * time.Hour)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 2 {
+		t.Errorf("expected 2 frames after removal, got %d", len(result))
+	}
+}
+
+func TestEvents(t *testing.T) {
+	open(t)
+
+	now := time.Now()
+	for i, score := range []float32{0.15, 0.35} {
+		start := now.Add(time.Duration(-i) * time.Hour)
+		if err := PutEvent(Event{ID: EventID(start), Camera: "garden", Start: start, MaxScore: score}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	result, err := Events(Filter{Since: now.Add(-2 * time.Hour), MinScore: 0.2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result) != 1 || result[0].MaxScore != 0.35 {
+		t.Errorf("unexpected events %v", result)
+	}
+}
+
+func TestMissingIndex(t *testing.T) {
+	cfg.Settings.IndexFile = filepath.Join(t.TempDir(), "missing.db")
+
+	result, err := Events(Filter{})
+	if err != nil || len(result) != 0 {
+		t.Errorf("expected no events from missing index, got %v, %v", result, err)
+	}
+}
+
+func TestViewWithoutInit(t *testing.T) {
+	open(t)
+	now := time.Now()
+	if err := PutEvent(Event{ID: EventID(now), Camera: "garden", Start: now}); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+
+	// CLI reads index read-only without holding it open
+	result, err := Events(Filter{Since: now.Add(-time.Hour)})
+	if err != nil || len(result) != 1 {
+		t.Errorf("expected 1 event from closed index, got %v, %v", result, err)
+	}
+	if err := AddFrame(Frame{Path: "images/a.jpg", Time: now}); err == nil {
+		t.Error("expected write to closed index to fail")
+	}
+}
+
+func TestReadWhileOpen(t *testing.T) {
+	open(t)
+	now := time.Now()
+	if err := AddFrame(Frame{Camera: "garden", Path: "images/a.jpg", Time: now}); err != nil {
+		t.Fatal(err)
+	}
+
+	// CLI in another process opens index while capture keeps accepting writes
+	d, err := bolt.Open(cfg.Settings.IndexFile, 0644, &bolt.Options{Timeout: 100 * time.Millisecond, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("index locked by open writer: %s", err)
+	}
+	d.Close()
+
+	// pending write is stored before read
+	result, err := Frames(Filter{Since: now.Add(-time.Hour)})
+	if err != nil || len(result) != 1 {
+		t.Errorf("expected pending frame, got %v, %v", result, err)
+	}
+}
+
+func TestRemoveEventFrames(t *testing.T) {
+	open(t)
+	now := time.Now()
+	id := EventID(now)
+	if err := PutEvent(Event{ID: id, Camera: "garden", Start: now, Frames: 2}); err != nil {
+		t.Fatal(err)
+	}
+	for i, path := range []string{"images/a.jpg", "images/b.jpg"} {
+		if err := AddFrame(Frame{Camera: "garden", Path: path, Time: now.Add(time.Duration(i) * time.Second), Event: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// event is kept while any of its frames is stored
+	if err := RemoveFrames([]string{"images/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := Events(Filter{}); err != nil || len(result) != 1 {
+		t.Fatalf("expected event with stored frame, got %v, %v", result, err)
+	}
+	if err := RemoveFrames([]string{"images/b.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := Events(Filter{}); err != nil || len(result) != 0 {
+		t.Errorf("expected event without frames to be removed, got %v, %v", result, err)
+	}
+}
@@ -0,0 +1,104 @@
+package local
+
+import (
+	"crypto/md5"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/file"
+)
+
+// Local copies files to local directory such as mounted NFS share
+type Local struct {
+	dir string
+}
+
+// New creates local directory uploader of given target
+func New(target cfg.ConfigTarget) *Local {
+	return &Local{target.Dir}
+}
+
+// Upload copies src file to dst path below target directory, the file appears under its name only when complete
+func (l *Local) Upload(src, dst string) error {
+	target := l.path(dst)
+	if err := file.CreateDir(filepath.Dir(target)); err != nil {
+		return err
+	}
+
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := ioutil.TempFile(filepath.Dir(target), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+
+	if err = out.Chmod(0644); err != nil {
+		out.Close()
+		return err
+	}
+	if _, err = io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err = out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), target)
+}
+
+// Size returns size of uploaded file
+func (l *Local) Size(dst string) (int64, error) {
+	fi, err := os.Stat(l.path(dst))
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+// Checksum returns MD5 of uploaded file
+func (l *Local) Checksum(dst string) (string, error) {
+	return file.Checksum(l.path(dst), md5.New())
+}
+
+// URL returns no location, files in local directory cannot be linked from notifications
+func (l *Local) URL(dst string) string {
+	return ""
+}
+
+func (l *Local) path(dst string) string {
+	return filepath.Join(l.dir, filepath.FromSlash(dst))
+}
+
+// Walk calls fn for every file and directory below root with path relative to target directory
+func (l *Local) Walk(root string, fn func(path string, info os.FileInfo) error) error {
+	base := l.path(root)
+	return filepath.Walk(base, func(p string, info os.FileInfo, err error) error {
+		if p == base {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(l.dir, p)
+		if err != nil {
+			return err
+		}
+		return fn(filepath.ToSlash(rel), info)
+	})
+}
+
+// Remove removes file or empty directory
+func (l *Local) Remove(dst string, dir bool) error {
+	return os.Remove(l.path(dst))
+}
@@ -0,0 +1,236 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/process"
+)
+
+// Published states
+const (
+	online  = "online"
+	offline = "offline"
+	on      = "ON"
+	off     = "OFF"
+)
+
+var client paho.Client
+
+// state holds last published values so that only changes are sent
+var state struct {
+	mu         sync.Mutex
+	motion     bool
+	lastMotion time.Time
+	score      string
+	armed      string
+}
+
+// Start connects to broker in background and publishes state of compared frames, broker is reconnected
+// when connection is lost and availability turns offline through last will
+func Start() {
+	c := cfg.MQTT
+	if !c.Enabled {
+		return
+	}
+	clientID := c.ClientID
+	if clientID == "" {
+		clientID = "watchdog-" + cfg.Settings.Id
+	}
+	opts := paho.NewClientOptions().
+		AddBroker(c.Broker).
+		SetClientID(clientID).
+		SetUsername(c.User).
+		SetPassword(c.Pass).
+		SetWill(topic("availability"), offline, 1, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(30 * time.Second).
+		// snapshot command takes seconds, it must not hold other messages
+		SetOrderMatters(false).
+		SetOnConnectHandler(onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			log.Printf("Lost connection to MQTT broker: %s\n", err)
+		})
+	client = paho.NewClient(opts)
+	client.Connect()
+	process.OnFrame(publishFrame)
+}
+
+// Stop publishes offline availability and disconnects
+func Stop() {
+	if client == nil {
+		return
+	}
+	client.Publish(topic("availability"), 1, true, offline).WaitTimeout(5 * time.Second)
+	client.Disconnect(1000)
+}
+
+// topic returns topic of camera below prefix such as watchdog/garden/motion
+func topic(name string) string {
+	return fmt.Sprintf("%s/%s/%s", cfg.MQTT.TopicPrefix, cfg.Settings.Id, name)
+}
+
+// onConnect announces camera and subscribes commands, it runs again after every reconnect
+func onConnect(c paho.Client) {
+	log.Println("Connected to MQTT broker")
+	subscribe(c, "armed/set", func(_ paho.Client, m paho.Message) { arm(string(m.Payload())) })
+	subscribe(c, "snapshot/set", func(_ paho.Client, m paho.Message) { snapshot() })
+	if cfg.MQTT.Discovery {
+		announce(c)
+	}
+	c.Publish(topic("availability"), 1, true, online)
+
+	state.mu.Lock()
+	state.armed = ""
+	state.mu.Unlock()
+	publishArmed()
+}
+
+func subscribe(c paho.Client, name string, handler paho.MessageHandler) {
+	t := c.Subscribe(topic(name), 1, handler)
+	go func() {
+		if t.WaitTimeout(10*time.Second) && t.Error() != nil {
+			log.Printf("Failed to subscribe %s: %s\n", topic(name), t.Error())
+		}
+	}()
+}
+
+// publish sends value without waiting for broker, messages are dropped while disconnected
+func publish(name string, retained bool, payload interface{}) {
+	if client == nil || !client.IsConnectionOpen() {
+		return
+	}
+	client.Publish(topic(name), 0, retained, payload)
+}
+
+// publishFrame updates motion and similarity index and publishes alert image
+func publishFrame(f process.Frame, alert bool) {
+	state.mu.Lock()
+	motion := f.Score > cfg.Settings.EmailThreshold
+	if motion {
+		state.lastMotion = f.Time
+	}
+	// motion ends once no motion was seen for timeout so that sensor does not flap
+	changed := false
+	if motion && !state.motion {
+		state.motion, changed = true, true
+	} else if !motion && state.motion && f.Time.Sub(state.lastMotion) >= time.Duration(cfg.MQTT.MotionTimeout)*time.Second {
+		state.motion, changed = false, true
+	}
+	score := fmt.Sprintf("%.2f", f.Score)
+	scoreChanged := score != state.score
+	state.score = score
+	motionState := state.motion
+	state.mu.Unlock()
+
+	if changed {
+		publish("motion", true, onOff(motionState))
+	}
+	if scoreChanged {
+		publish("similarity", true, score)
+	}
+	if alert {
+		publishImage("alert", f.Path)
+	}
+	publishArmed()
+}
+
+// publishArmed publishes armed state when it changed, such as by Telegram command
+func publishArmed() {
+	armed := onOff(process.Armed())
+	state.mu.Lock()
+	changed := armed != state.armed
+	state.armed = armed
+	state.mu.Unlock()
+	if changed {
+		publish("armed", true, armed)
+	}
+}
+
+func publishImage(name, path string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		log.Printf("Failed to read image for MQTT (%s): %s\n", path, err)
+		return
+	}
+	publish(name, true, data)
+}
+
+func arm(payload string) {
+	switch strings.ToUpper(strings.TrimSpace(payload)) {
+	case on:
+		process.Arm(true)
+	case off:
+		process.Arm(false)
+	default:
+		log.Printf("Unknown MQTT armed command %q\n", payload)
+		return
+	}
+	log.Printf("Armed set to %s over MQTT\n", payload)
+	publishArmed()
+}
+
+func snapshot() {
+	path, err := process.Snapshot()
+	if err != nil {
+		log.Printf("Failed to capture MQTT snapshot: %s\n", err)
+		return
+	}
+	defer os.Remove(path)
+	publishImage("snapshot", path)
+}
+
+func onOff(v bool) string {
+	if v {
+		return on
+	}
+	return off
+}
+
+// announce publishes Home Assistant discovery configuration so that camera entities appear automatically
+func announce(c paho.Client) {
+	id := cfg.Settings.Id
+	device := map[string]interface{}{
+		"identifiers":  []string{"watchdog_" + id},
+		"name":         "Watchdog " + id,
+		"manufacturer": "watchdog",
+	}
+	entities := []struct {
+		component string
+		object    string
+		config    map[string]interface{}
+	}{
+		{"binary_sensor", "motion", map[string]interface{}{"name": "Motion", "device_class": "motion",
+			"state_topic": topic("motion"), "payload_on": on, "payload_off": off}},
+		{"sensor", "similarity", map[string]interface{}{"name": "Similarity index", "state_topic": topic("similarity"),
+			"state_class": "measurement", "icon": "mdi:image-filter-center-focus"}},
+		{"camera", "alert", map[string]interface{}{"name": "Last alert", "topic": topic("alert")}},
+		{"camera", "snapshot", map[string]interface{}{"name": "Snapshot", "topic": topic("snapshot")}},
+		{"switch", "armed", map[string]interface{}{"name": "Armed", "state_topic": topic("armed"),
+			"command_topic": topic("armed/set"), "payload_on": on, "payload_off": off, "icon": "mdi:shield-home"}},
+		{"button", "snapshot", map[string]interface{}{"name": "Take snapshot", "command_topic": topic("snapshot/set"),
+			"icon": "mdi:camera"}},
+	}
+	for _, e := range entities {
+		e.config["unique_id"] = fmt.Sprintf("watchdog_%s_%s_%s", id, e.object, e.component)
+		e.config["object_id"] = fmt.Sprintf("watchdog_%s_%s", id, e.object)
+		e.config["availability_topic"] = topic("availability")
+		e.config["device"] = device
+		payload, err := json.Marshal(e.config)
+		if err != nil {
+			log.Printf("Failed to encode discovery of %s: %s\n", e.object, err)
+			continue
+		}
+		t := fmt.Sprintf("%s/%s/watchdog_%s/%s/config", cfg.MQTT.DiscoveryPrefix, e.component, id, e.object)
+		c.Publish(t, 1, true, payload)
+	}
+}
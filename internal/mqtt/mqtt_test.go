@@ -0,0 +1,100 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/process"
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/mochi-mqtt/server/v2/packets"
+)
+
+func TestMQTT(t *testing.T) {
+	server := mochi.New(&mochi.Options{InlineClient: true})
+	if err := server.AddHook(new(auth.AllowHook), nil); err != nil {
+		t.Fatal(err)
+	}
+	tcp := listeners.NewTCP(listeners.Config{ID: "test", Address: "127.0.0.1:0"})
+	if err := server.AddListener(tcp); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	defer server.Close()
+
+	var mu sync.Mutex
+	messages := map[string]string{}
+	err := server.Subscribe("#", 1, func(_ *mochi.Client, _ packets.Subscription, pk packets.Packet) {
+		mu.Lock()
+		messages[pk.TopicName] = string(pk.Payload)
+		mu.Unlock()
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	wait := func(topic, want string) {
+		t.Helper()
+		for start := time.Now(); time.Since(start) < 5*time.Second; time.Sleep(10 * time.Millisecond) {
+			mu.Lock()
+			got := messages[topic]
+			mu.Unlock()
+			if got == want {
+				return
+			}
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		t.Fatalf("%s is %q, want %q", topic, messages[topic], want)
+	}
+
+	cfg.Settings.Id = "garden"
+	cfg.Settings.EmailThreshold = 0.2
+	cfg.MQTT = cfg.ConfigMQTT{Enabled: true, Broker: "tcp://" + tcp.Address(), TopicPrefix: "watchdog",
+		Discovery: true, DiscoveryPrefix: "homeassistant", MotionTimeout: 30}
+	Start()
+	defer Stop()
+	wait("watchdog/garden/availability", "online")
+	wait("watchdog/garden/armed", "ON")
+
+	mu.Lock()
+	var discovery map[string]interface{}
+	json.Unmarshal([]byte(messages["homeassistant/binary_sensor/watchdog_garden/motion/config"]), &discovery)
+	mu.Unlock()
+	if discovery["state_topic"] != "watchdog/garden/motion" || discovery["availability_topic"] != "watchdog/garden/availability" {
+		t.Fatalf("unexpected discovery %v", discovery)
+	}
+
+	image := filepath.Join(t.TempDir(), "alert.jpg")
+	if err := os.WriteFile(image, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	publishFrame(process.Frame{Path: image, Time: now, Score: 0.5}, true)
+	wait("watchdog/garden/motion", "ON")
+	wait("watchdog/garden/similarity", "0.50")
+	wait("watchdog/garden/alert", "jpeg")
+
+	publishFrame(process.Frame{Time: now.Add(10 * time.Second), Score: 0.1}, false)
+	wait("watchdog/garden/similarity", "0.10")
+	wait("watchdog/garden/motion", "ON")
+	publishFrame(process.Frame{Time: now.Add(31 * time.Second), Score: 0.1}, false)
+	wait("watchdog/garden/motion", "OFF")
+
+	if err := server.Publish("watchdog/garden/armed/set", []byte("OFF"), false, 1); err != nil {
+		t.Fatal(err)
+	}
+	wait("watchdog/garden/armed", "OFF")
+	if process.Armed() {
+		t.Fatal("camera should be disarmed")
+	}
+	process.Arm(true)
+
+	Stop()
+	wait("watchdog/garden/availability", "offline")
+}
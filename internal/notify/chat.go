@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// colors of chat messages by severity, recovery is green
+var colors = map[Severity]string{
+	SeverityInfo:     "#1e88e5",
+	SeverityWarning:  "#f9a825",
+	SeverityCritical: "#d32f2f",
+}
+
+const recoveryColor = "#2e7d32"
+
+// color returns hex color of notification
+func color(n Notification) string {
+	if n.Kind == KindRecovery {
+		return recoveryColor
+	}
+	return colors[n.Severity()]
+}
+
+// field is labeled value shown in chat message
+type field struct {
+	title string
+	value string
+}
+
+// fields returns time, score and links of notification
+func fields(n Notification) []field {
+	f := []field{{"Time", n.Time.Format(time.RFC3339)}}
+	if n.Score > 0 {
+		f = append(f, field{"Score", fmt.Sprintf("%.2f", n.Score)})
+	}
+	if len(n.Links) > 0 {
+		f = append(f, field{"Links", strings.Join(n.Links, "\n")})
+	}
+	return f
+}
+
+// imageURL returns web link of uploaded image or of uploaded event montage when there is one
+func imageURL(n Notification) string {
+	if isWebImage(n.ImageURL) {
+		// encrypted upload cannot be shown
+		return n.ImageURL
+	}
+	for _, link := range n.Links {
+		if isWebImage(link) {
+			return link
+		}
+	}
+	return ""
+}
+
+// isWebImage reports whether chat service can fetch image from link, it rejects whole message otherwise
+func isWebImage(link string) bool {
+	return (strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://")) && isImage(link)
+}
+
+func isImage(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jpg", ".jpeg", ".png", ".gif":
+		return true
+	}
+	return false
+}
+
+// incident is message about failure waiting for recovery
+type incident struct {
+	id string
+	n  Notification
+}
+
+// incidents remembers messages of unresolved failures so that recovery can thread under or edit them
+type incidents struct {
+	mu   sync.Mutex
+	open map[Kind]incident
+}
+
+// opened returns message id of unresolved failure of given kind
+func (i *incidents) opened(kind Kind) string {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.open[kind].id
+}
+
+// add records message of failure unless the failure is already open
+func (i *incidents) add(n Notification, id string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	if i.open == nil {
+		i.open = map[Kind]incident{}
+	}
+	if _, ok := i.open[n.Kind]; !ok {
+		i.open[n.Kind] = incident{id, n}
+	}
+}
+
+// resolve forgets failure resolved by recovery and returns its message, ok is false when there is none
+func (i *incidents) resolve(n Notification) (incident, bool) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	inc, ok := i.open[n.Resolves]
+	delete(i.open, n.Resolves)
+	return inc, ok
+}
+
+// isFailure reports whether notification kind is resolved by recovery, uploads are retried without one
+func isFailure(kind Kind) bool {
+	return kind == KindCaptureFailure || kind == KindDiskLow
+}
@@ -0,0 +1,223 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// recorder fakes chat server recording request paths and JSON payloads
+type recorder struct {
+	mu       sync.Mutex
+	requests []string
+	payloads []map[string]interface{}
+	reply    func(r *http.Request) string
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	var payload map[string]interface{}
+	switch {
+	case strings.HasPrefix(r.Header.Get("Content-Type"), "application/json"):
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &payload)
+	case strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/"):
+		json.Unmarshal([]byte(r.FormValue("payload_json")), &payload)
+		for k := range r.MultipartForm.File {
+			payload[k] = true
+		}
+	default:
+		r.ParseForm()
+		payload = map[string]interface{}{}
+		for k := range r.Form {
+			payload[k] = r.Form.Get(k)
+		}
+	}
+	rec.requests = append(rec.requests, r.Method+" "+r.URL.Path)
+	rec.payloads = append(rec.payloads, payload)
+	if rec.reply != nil {
+		fmt.Fprint(w, rec.reply(r))
+	}
+}
+
+// get returns value of payload at dotted path such as attachments.0.color
+func get(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			v = node[key]
+		case []interface{}:
+			var i int
+			fmt.Sscan(key, &i)
+			if i >= len(node) {
+				return nil
+			}
+			v = node[i]
+		default:
+			return nil
+		}
+	}
+	return v
+}
+
+func alertImage(t *testing.T) string {
+	image := filepath.Join(t.TempDir(), "alert.jpg")
+	if err := os.WriteFile(image, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return image
+}
+
+func TestSlackWebhook(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	s := NewSlack(cfg.ConfigNotifier{URL: server.URL + "/services/T/B/X"})
+	n := Notification{Kind: KindEvent, Camera: "garden", Time: time.Now(), Text: "event", Score: 0.4,
+		Links: []string{"https://example.com/clip.mp4", "https://example.com/montage.jpg"}}
+	if err := s.Notify(n); err != nil {
+		t.Fatal(err)
+	}
+	p := rec.payloads[0]
+	if rec.requests[0] != "POST /services/T/B/X" || get(p, "text") != "CAMERA EVENT: garden" || get(p, "attachments.0.color") != "#f9a825" {
+		t.Fatalf("unexpected request %s %v", rec.requests[0], p)
+	}
+	if get(p, "attachments.0.blocks.2.image_url") != "https://example.com/montage.jpg" {
+		t.Fatalf("image is not linked: %v", p)
+	}
+
+	// alert links uploaded frame, encrypted one or one not served over HTTP cannot be shown
+	tests := []struct{ url, want string }{
+		{"https://example.com/0007-0001.jpg", "https://example.com/0007-0001.jpg"},
+		{"https://example.com/0007-0002.jpg.age", ""},
+		{"ftp://nas/0007-0003.jpg", ""},
+		{"/srv/remote/0007-0004.jpg", ""},
+	}
+	for i, tt := range tests {
+		if err := s.Notify(Notification{Kind: KindAlert, Camera: "garden", Time: time.Now(), Score: 0.4, ImageURL: tt.url}); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := get(rec.payloads[i+1], "attachments.0.blocks.2.image_url").(string)
+		if got != tt.want {
+			t.Errorf("image of %s: got %q, want %q", tt.url, got, tt.want)
+		}
+	}
+}
+
+func TestSlackThreads(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+	rec.reply = func(r *http.Request) string {
+		if r.Header.Get("Authorization") != "Bearer xoxb-1" && r.URL.Path != "/upload" {
+			return `{"ok":false,"error":"invalid_auth"}`
+		}
+		switch r.URL.Path {
+		case "/api/chat.postMessage":
+			return fmt.Sprintf(`{"ok":true,"channel":"C1","ts":"%d.0"}`, len(rec.requests))
+		case "/api/files.getUploadURLExternal":
+			return fmt.Sprintf(`{"ok":true,"upload_url":"%s/upload","file_id":"F1"}`, "http://"+r.Host)
+		}
+		return `{"ok":true}`
+	}
+
+	s := NewSlack(cfg.ConfigNotifier{URL: server.URL + "/api", Token: "xoxb-1", Channel: "#camera"})
+	now := time.Now()
+	for _, n := range []Notification{
+		{Kind: KindCaptureFailure, Time: now, Text: "timeout"},
+		{Kind: KindCaptureFailure, Time: now, Text: "timeout"},
+		{Kind: KindRecovery, Time: now, Text: "recovered", Resolves: KindCaptureFailure},
+		{Kind: KindAlert, Time: now, Image: alertImage(t)},
+	} {
+		if err := s.Notify(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{
+		"POST /api/chat.postMessage",
+		"POST /api/chat.postMessage",
+		"POST /api/chat.postMessage",
+		"POST /api/chat.postMessage",
+		"POST /api/files.getUploadURLExternal",
+		"POST /upload",
+		"POST /api/files.completeUploadExternal",
+	}
+	if strings.Join(rec.requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests\n%s", strings.Join(rec.requests, "\n"))
+	}
+	if get(rec.payloads[0], "thread_ts") != nil || get(rec.payloads[0], "channel") != "#camera" {
+		t.Fatalf("first failure should start thread: %v", rec.payloads[0])
+	}
+	if get(rec.payloads[1], "thread_ts") != "1.0" || get(rec.payloads[2], "thread_ts") != "1.0" || get(rec.payloads[2], "reply_broadcast") != true {
+		t.Fatalf("failure and recovery should reply in thread: %v %v", rec.payloads[1], rec.payloads[2])
+	}
+	if get(rec.payloads[3], "thread_ts") != nil || get(rec.payloads[6], "channel_id") != "C1" || get(rec.payloads[4], "filename") != "alert.jpg" {
+		t.Fatalf("unexpected alert upload: %v", rec.payloads[3:])
+	}
+}
+
+func TestDiscord(t *testing.T) {
+	rec := &recorder{reply: func(r *http.Request) string { return `{"id":"99"}` }}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	d := NewDiscord(cfg.ConfigNotifier{URL: server.URL + "/api/webhooks/1/abc"})
+	now := time.Now()
+	for _, n := range []Notification{
+		{Kind: KindAlert, Camera: "garden", Time: now, Score: 0.7, Image: alertImage(t)},
+		{Kind: KindDiskLow, Camera: "garden", Time: now, Text: "Only 10 MB free"},
+		{Kind: KindRecovery, Camera: "garden", Time: now, Text: "Disk space recovered", Resolves: KindDiskLow},
+	} {
+		if err := d.Notify(n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := []string{
+		"POST /api/webhooks/1/abc",
+		"POST /api/webhooks/1/abc",
+		"PATCH /api/webhooks/1/abc/messages/99",
+		"POST /api/webhooks/1/abc",
+	}
+	if strings.Join(rec.requests, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected requests\n%s", strings.Join(rec.requests, "\n"))
+	}
+	alert := rec.payloads[0]
+	if get(alert, "files[0]") != true || get(alert, "embeds.0.image.url") != "attachment://alert.jpg" || get(alert, "embeds.0.color") != float64(0xd32f2f) {
+		t.Fatalf("unexpected alert %v", alert)
+	}
+	edit := rec.payloads[2]
+	if get(edit, "embeds.0.title") != "RESOLVED DISK LOW: garden" || get(edit, "embeds.0.color") != float64(0x2e7d32) {
+		t.Fatalf("unexpected edit %v", edit)
+	}
+}
+
+func TestMattermost(t *testing.T) {
+	rec := &recorder{}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	m := NewMattermost(cfg.ConfigNotifier{URL: server.URL + "/hooks/xyz", Channel: "town-square"})
+	n := Notification{Kind: KindUploadFailure, Camera: "garden", Time: time.Now(), Text: "Failed to upload"}
+	if err := m.Notify(n); err != nil {
+		t.Fatal(err)
+	}
+	p := rec.payloads[0]
+	if get(p, "channel") != "town-square" || get(p, "attachments.0.title") != "CAMERA UPLOAD FAILURE: garden" ||
+		get(p, "attachments.0.color") != "#f9a825" || get(p, "attachments.0.fields.0.title") != "Time" {
+		t.Fatalf("unexpected payload %v", p)
+	}
+}
@@ -0,0 +1,138 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// discordFileLimit is upload limit of webhooks without boosted server, larger files are linked only
+const discordFileLimit = 8 * 1024 * 1024
+
+// Discord posts notifications as embeds with uploaded images to channel webhook. Failure message is
+// edited to resolved state when recovery arrives.
+type Discord struct {
+	url     string
+	retries int
+	client  *http.Client
+	threads incidents
+}
+
+// NewDiscord creates discord notifier of webhook url
+func NewDiscord(c cfg.ConfigNotifier) *Discord {
+	return &Discord{url: c.URL, retries: c.Retries, client: httpClient(c)}
+}
+
+// Notify posts embed with files and edits failure resolved by recovery
+func (d *Discord) Notify(n Notification) error {
+	if n.Kind == KindRecovery {
+		if inc, ok := d.threads.resolve(n); ok {
+			d.resolve(inc, n)
+		}
+	}
+
+	var files []formFile
+	embed := discordEmbed(n)
+	for _, f := range n.Files() {
+		if fi, err := os.Stat(f); err != nil || fi.Size() > discordFileLimit {
+			continue
+		}
+		if len(files) == 0 && isImage(f) {
+			embed["image"] = map[string]string{"url": "attachment://" + filepath.Base(f)}
+		}
+		files = append(files, formFile{fmt.Sprintf("files[%d]", len(files)), f})
+	}
+	payload, err := json.Marshal(map[string]interface{}{"embeds": []interface{}{embed}})
+	if err != nil {
+		return err
+	}
+	body, contentType, err := multipartForm(url.Values{"payload_json": {string(payload)}}, files...)
+	if err != nil {
+		return err
+	}
+	data, err := do(d.client, d.retries, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, d.endpoint("", "wait=true"), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
+	if err != nil {
+		return err
+	}
+	if isFailure(n.Kind) {
+		var msg struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal(data, &msg); err == nil && msg.ID != "" {
+			d.threads.add(n, msg.ID)
+		}
+	}
+	return nil
+}
+
+// resolve edits failure message to show it was resolved, failure to edit does not stop recovery message
+func (d *Discord) resolve(inc incident, recovery Notification) {
+	embed := discordEmbed(inc.n)
+	embed["title"] = "RESOLVED " + inc.n.Subject()
+	embed["color"] = colorValue(recoveryColor)
+	embed["description"] = fmt.Sprintf("%s\nResolved %s: %s", inc.n.Text, recovery.Time.Format(time.RFC3339), recovery.Text)
+	_, err := sendJSON(d.client, d.retries, http.MethodPatch, d.endpoint("/messages/"+inc.id, ""), nil,
+		map[string]interface{}{"embeds": []interface{}{embed}})
+	if err != nil {
+		log.Printf("Failed to edit discord failure message: %s\n", err)
+	}
+}
+
+// endpoint appends path and query to webhook url which may have query of its own such as thread_id
+func (d *Discord) endpoint(path, query string) string {
+	u, err := url.Parse(d.url)
+	if err != nil {
+		return d.url
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	if query != "" {
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += query
+	}
+	return u.String()
+}
+
+func discordEmbed(n Notification) map[string]interface{} {
+	var fs []map[string]interface{}
+	for _, f := range fields(n) {
+		fs = append(fs, map[string]interface{}{"name": f.title, "value": f.value, "inline": f.title != "Links"})
+	}
+	embed := map[string]interface{}{
+		"title":       n.Subject(),
+		"description": n.Text,
+		"color":       colorValue(color(n)),
+		"fields":      fs,
+	}
+	if !n.Time.IsZero() {
+		embed["timestamp"] = n.Time.Format(time.RFC3339)
+	}
+	if u := imageURL(n); u != "" {
+		embed["image"] = map[string]string{"url": u}
+	}
+	return embed
+}
+
+// colorValue converts hex color to integer used by embeds
+func colorValue(hex string) int {
+	v, _ := strconv.ParseInt(strings.TrimPrefix(hex, "#"), 16, 32)
+	return int(v)
+}
@@ -0,0 +1,167 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/email"
+)
+
+const (
+	defaultEmailSubject = `{{.Subject}}`
+	defaultEmailText    = `{{.Timestamp}} {{.Text}}{{range .Links}}
+{{.}}{{end}}`
+	defaultEmailHTML = `<html><body style="font-family: sans-serif">
+<h2 style="color: {{.Color}}">{{.Subject}}</h2>
+<p>{{.Text}}</p>
+<table cellpadding="4">
+<tr><td>Time</td><td>{{.Timestamp}}</td></tr>
+{{- if .Score}}
+<tr><td>Score</td><td>{{printf "%.2f" .Score}}</td></tr>
+{{- end}}
+{{- with .Event}}
+<tr><td>Event</td><td>{{.Start.Format "15:04:05"}} - {{.End.Format "15:04:05"}}, {{.Frames}} frames, max score {{printf "%.2f" .MaxScore}}</td></tr>
+{{- end}}
+</table>
+{{- range .Images}}
+<p><img src="{{.Src}}" alt="{{.Name}}" style="max-width: 100%"></p>
+{{- end}}
+{{- if .Links}}
+<ul>{{range .Links}}<li><a href="{{.}}">{{.}}</a></li>{{end}}</ul>
+{{- end}}
+</body></html>`
+)
+
+// Email sends notifications through configured SMTP server with text and HTML body rendered from
+// templates, images are embedded inline and other files attached
+type Email struct {
+	to      []string
+	cc      []string
+	bcc     []string
+	subject *template.Template
+	text    *template.Template
+	html    *htmltemplate.Template
+}
+
+// emailData is available to subject and body templates
+type emailData struct {
+	Subject     string
+	Kind        string
+	Severity    string
+	Color       string
+	Camera      string
+	Time        time.Time
+	Timestamp   string
+	Text        string
+	Score       float32
+	Event       *Event
+	Images      []emailImage
+	Attachments []string
+	Links       []string
+}
+
+// emailImage is inline image, Src references it from HTML body
+type emailImage struct {
+	Name string
+	Src  htmltemplate.URL
+}
+
+// NewEmail creates email notifier, recipients of notifier override those of SMTP configuration
+func NewEmail(c cfg.ConfigNotifier) (*Email, error) {
+	e := &Email{to: c.To, cc: c.CC, bcc: c.BCC}
+	if len(e.to)+len(e.cc)+len(e.bcc) == 0 {
+		e.to, e.cc, e.bcc = email.Recipients()
+	}
+	var err error
+	subject := cfg.SMTP.Subject
+	if subject == "" {
+		subject = defaultEmailSubject
+	}
+	if e.subject, err = template.New("subject").Parse(subject); err != nil {
+		return nil, err
+	}
+	text, err := readTemplate(cfg.SMTP.TextTemplate, defaultEmailText)
+	if err != nil {
+		return nil, err
+	}
+	if e.text, err = template.New("text").Parse(text); err != nil {
+		return nil, err
+	}
+	html, err := readTemplate(cfg.SMTP.HTMLTemplate, defaultEmailHTML)
+	if err != nil {
+		return nil, err
+	}
+	if e.html, err = htmltemplate.New("html").Parse(html); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// readTemplate reads template file, empty path uses default template
+func readTemplate(path, def string) (string, error) {
+	if path == "" {
+		return def, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	return string(data), err
+}
+
+// Notify sends notification as email
+func (e *Email) Notify(n Notification) error {
+	m, err := e.message(n)
+	if err != nil {
+		return err
+	}
+	return email.Send(m)
+}
+
+// message renders templates, images are embedded inline and other files such as clips attached
+func (e *Email) message(n Notification) (email.Message, error) {
+	m := email.Message{To: e.to, CC: e.cc, BCC: e.bcc}
+	data := emailData{
+		Subject:   n.Subject(),
+		Kind:      string(n.Kind),
+		Severity:  n.Severity().String(),
+		Color:     color(n),
+		Camera:    n.Camera,
+		Time:      n.Time,
+		Timestamp: n.Time.Format(time.RFC3339),
+		Text:      n.Text,
+		Score:     n.Score,
+		Event:     n.Event,
+		Links:     n.Links,
+	}
+	for _, f := range n.Files() {
+		if !isImage(f) {
+			m.Attachments = append(m.Attachments, f)
+			data.Attachments = append(data.Attachments, filepath.Base(f))
+			continue
+		}
+		cid := fmt.Sprintf("image%d@watchdog", len(m.Inline)+1)
+		m.Inline = append(m.Inline, email.Inline{Path: f, CID: cid})
+		data.Images = append(data.Images, emailImage{filepath.Base(f), htmltemplate.URL("cid:" + cid)})
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := e.subject.Execute(&subject, data); err != nil {
+		return m, err
+	}
+	if err := e.text.Execute(&text, data); err != nil {
+		return m, err
+	}
+	if err := e.html.Execute(&html, data); err != nil {
+		return m, err
+	}
+	// subject must be single line
+	m.Subject = strings.Join(strings.Fields(subject.String()), " ")
+	m.Text = text.String()
+	m.HTML = html.String()
+	return m, nil
+}
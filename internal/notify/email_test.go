@@ -0,0 +1,70 @@
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+func TestEmailMessage(t *testing.T) {
+	dir := t.TempDir()
+	montage := filepath.Join(dir, "montage.jpg")
+	clip := filepath.Join(dir, "clip.mp4")
+	cfg.SMTP = cfg.ConfigSMTP{Receiver: "owner@example.com", CC: []string{"team@example.com"}}
+
+	e, err := NewEmail(cfg.ConfigNotifier{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	start := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	n := Notification{Kind: KindEvent, Camera: "garden", Time: start, Text: "event <front>", Score: 0.42,
+		Image: alertImage(t), Attachments: []string{clip, montage}, Links: []string{"https://example.com/clip.mp4"},
+		Event: &Event{Start: start, End: start.Add(90 * time.Second), Frames: 12, MaxScore: 0.42}}
+	m, err := e.message(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "CAMERA EVENT: garden" || strings.Join(m.To, ",") != "owner@example.com" || strings.Join(m.CC, ",") != "team@example.com" {
+		t.Fatalf("unexpected subject or recipients %+v", m)
+	}
+	if m.Text != "2021-01-01T12:00:00Z event <front>\nhttps://example.com/clip.mp4" {
+		t.Fatalf("unexpected text %q", m.Text)
+	}
+	if len(m.Inline) != 2 || m.Inline[1].Path != montage || len(m.Attachments) != 1 || m.Attachments[0] != clip {
+		t.Fatalf("unexpected files %+v %+v", m.Inline, m.Attachments)
+	}
+	for _, want := range []string{`src="cid:image1@watchdog"`, `src="cid:image2@watchdog"`, "event &lt;front&gt;",
+		"12:00:00 - 12:01:30, 12 frames", `href="https://example.com/clip.mp4"`} {
+		if !strings.Contains(m.HTML, want) {
+			t.Fatalf("html does not contain %s:\n%s", want, m.HTML)
+		}
+	}
+
+	// templates and recipients of notifier
+	text := filepath.Join(dir, "text.tmpl")
+	if err := os.WriteFile(text, []byte("{{.Camera}} {{.Kind}} {{range .Attachments}}{{.}} {{end}}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg.SMTP.Subject = "[{{.Severity}}] {{.Camera}}\n{{printf \"%.1f\" .Score}}"
+	cfg.SMTP.TextTemplate = text
+	e, err = NewEmail(cfg.ConfigNotifier{BCC: []string{"archive@example.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m, err = e.message(n); err != nil {
+		t.Fatal(err)
+	}
+	if m.Subject != "[warning] garden 0.4" || m.Text != "garden event clip.mp4 " || len(m.To) != 0 || m.BCC[0] != "archive@example.com" {
+		t.Fatalf("unexpected message %+v", m)
+	}
+
+	cfg.SMTP.HTMLTemplate = filepath.Join(dir, "missing.html")
+	if _, err := NewEmail(cfg.ConfigNotifier{}); err == nil {
+		t.Fatal("missing template should fail")
+	}
+	cfg.SMTP = cfg.ConfigSMTP{}
+}
@@ -0,0 +1,66 @@
+package notify
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// gotify priorities are 0 to 10, Android app shows 8 and above as high priority
+var (
+	gotifyBands    = []cfg.ConfigPriority{{Score: 0.5, Priority: 8}, {Score: 0.2, Priority: 5}}
+	gotifySeverity = map[Severity]int{SeverityInfo: 2, SeverityWarning: 5, SeverityCritical: 8}
+)
+
+// Gotify sends notifications to Gotify server application, images are linked when uploaded to a target
+type Gotify struct {
+	url        string
+	token      string
+	click      string
+	priorities priorities
+	retries    int
+	client     *http.Client
+}
+
+// NewGotify creates gotify notifier of application token
+func NewGotify(c cfg.ConfigNotifier) *Gotify {
+	return &Gotify{
+		url:        strings.TrimSuffix(c.URL, "/") + "/message",
+		token:      c.Token,
+		click:      c.Click,
+		priorities: newPriorities(c.Priorities, gotifyBands, gotifySeverity),
+		retries:    c.Retries,
+		client:     httpClient(c),
+	}
+}
+
+// Notify sends message with click URL and image shown by Android app
+func (g *Gotify) Notify(n Notification) error {
+	message := fmt.Sprintf("%s %s", n.Time.Format(time.RFC3339), n.Text)
+	if n.Score > 0 {
+		message += fmt.Sprintf(" (score %.2f)", n.Score)
+	}
+	for _, link := range n.Links {
+		message += "\n" + link
+	}
+	notification := map[string]interface{}{}
+	if click := clickURL(n, g.click); click != "" {
+		notification["click"] = map[string]string{"url": click}
+	}
+	if u := imageURL(n); u != "" {
+		notification["bigImageUrl"] = u
+	}
+	msg := map[string]interface{}{
+		"title":    n.Subject(),
+		"message":  message,
+		"priority": g.priorities.of(n),
+	}
+	if len(notification) > 0 {
+		msg["extras"] = map[string]interface{}{"client::notification": notification}
+	}
+	_, err := sendJSON(g.client, g.retries, http.MethodPost, g.url, map[string]string{"X-Gotify-Key": g.token}, msg)
+	return err
+}
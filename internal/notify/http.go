@@ -0,0 +1,132 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// retryDelay is delay before first retry, it doubles with each attempt
+var retryDelay = time.Second
+
+// httpClient returns client with configured timeout, 10 seconds by default
+func httpClient(c cfg.ConfigNotifier) *http.Client {
+	timeout := 10 * time.Second
+	if c.Timeout > 0 {
+		timeout = time.Duration(c.Timeout) * time.Second
+	}
+	return &http.Client{Timeout: timeout}
+}
+
+// do sends request built by newRequest and returns response body. Network errors, rate limiting and
+// server errors are retried up to given number of times, request is built again for each attempt.
+func do(client *http.Client, retries int, newRequest func() (*http.Request, error)) ([]byte, error) {
+	var err error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			time.Sleep(retryDelay << (attempt - 1))
+		}
+		var req *http.Request
+		req, err = newRequest()
+		if err != nil {
+			return nil, err
+		}
+		var body []byte
+		var retry bool
+		body, retry, err = send(client, req)
+		if err == nil || !retry {
+			return body, err
+		}
+	}
+	return nil, err
+}
+
+func send(client *http.Client, req *http.Request) ([]byte, bool, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		// webhook and bot URLs contain secrets which must not be logged
+		if uerr, ok := err.(*url.Error); ok {
+			err = fmt.Errorf("%s %s failed: %s", req.Method, req.URL.Host, uerr.Err)
+		}
+		return nil, true, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, true, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		return body, retry, fmt.Errorf("%s %s failed: %s", req.Method, req.URL.Host, resp.Status)
+	}
+	return body, false, nil
+}
+
+// sendJSON sends v encoded as JSON and returns response body
+func sendJSON(client *http.Client, retries int, method, endpoint string, headers map[string]string, v interface{}) ([]byte, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return do(client, retries, func() (*http.Request, error) {
+		req, err := http.NewRequest(method, endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		return req, nil
+	})
+}
+
+// formFile is file sent as multipart field
+type formFile struct {
+	field string
+	path  string
+}
+
+// multipartForm encodes params followed by files
+func multipartForm(params url.Values, files ...formFile) ([]byte, string, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for k := range params {
+		if err := mw.WriteField(k, params.Get(k)); err != nil {
+			return nil, "", err
+		}
+	}
+	for _, file := range files {
+		if err := writeFile(mw, file); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
+
+func writeFile(mw *multipart.Writer, file formFile) error {
+	f, err := os.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fw, err := mw.CreateFormFile(file.field, filepath.Base(file.path))
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(fw, f)
+	return err
+}
@@ -0,0 +1,45 @@
+package notify
+
+import (
+	"net/http"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// Mattermost posts notifications as color coded attachments to incoming webhook. Webhooks cannot
+// upload files or reply to posts, so images are linked when they were uploaded to a target.
+type Mattermost struct {
+	url     string
+	channel string
+	retries int
+	client  *http.Client
+}
+
+// NewMattermost creates mattermost notifier, channel overrides default channel of webhook
+func NewMattermost(c cfg.ConfigNotifier) *Mattermost {
+	return &Mattermost{url: c.URL, channel: c.Channel, retries: c.Retries, client: httpClient(c)}
+}
+
+// Notify posts notification attachment
+func (m *Mattermost) Notify(n Notification) error {
+	var fs []map[string]interface{}
+	for _, f := range fields(n) {
+		fs = append(fs, map[string]interface{}{"title": f.title, "value": f.value, "short": f.title != "Links"})
+	}
+	attachment := map[string]interface{}{
+		"fallback": n.Subject() + " " + n.Text,
+		"color":    color(n),
+		"title":    n.Subject(),
+		"text":     n.Text,
+		"fields":   fs,
+	}
+	if u := imageURL(n); u != "" {
+		attachment["image_url"] = u
+	}
+	msg := map[string]interface{}{"attachments": []interface{}{attachment}}
+	if m.channel != "" {
+		msg["channel"] = m.channel
+	}
+	_, err := sendJSON(m.client, m.retries, http.MethodPost, m.url, nil, msg)
+	return err
+}
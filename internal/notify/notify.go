@@ -0,0 +1,311 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/worker"
+)
+
+// Kind is type of notification
+type Kind string
+
+// Notification kinds
+const (
+	KindStart          Kind = "start"
+	KindAlert          Kind = "alert"
+	KindEvent          Kind = "event"
+	KindCaptureFailure Kind = "capture-failure"
+	KindUploadFailure  Kind = "upload-failure"
+	KindDiskLow        Kind = "disk-low"
+	KindTamper         Kind = "tamper"
+	KindRecovery       Kind = "recovery"
+)
+
+// Kinds lists all notification kinds
+var Kinds = []Kind{KindStart, KindAlert, KindEvent, KindCaptureFailure, KindUploadFailure, KindDiskLow, KindTamper, KindRecovery}
+
+// Severity orders notifications by importance
+type Severity int
+
+// Severity levels
+const (
+	SeverityInfo Severity = iota
+	SeverityWarning
+	SeverityCritical
+)
+
+var severityNames = map[string]Severity{"info": SeverityInfo, "warning": SeverityWarning, "critical": SeverityCritical}
+
+// ParseSeverity parses severity name, empty name is info
+func ParseSeverity(name string) (Severity, error) {
+	if name == "" {
+		return SeverityInfo, nil
+	}
+	s, ok := severityNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown severity %q", name)
+	}
+	return s, nil
+}
+
+func (s Severity) String() string {
+	for name, v := range severityNames {
+		if v == s {
+			return name
+		}
+	}
+	return "unknown"
+}
+
+// kinds holds severity and subject of each notification kind
+var kinds = map[Kind]struct {
+	severity Severity
+	subject  string
+}{
+	KindStart:          {SeverityInfo, "CAMERA START"},
+	KindAlert:          {SeverityCritical, "CAMERA ALERT"},
+	KindEvent:          {SeverityWarning, "CAMERA EVENT"},
+	KindCaptureFailure: {SeverityCritical, "CAMERA CAPTURE FAILURE"},
+	KindUploadFailure:  {SeverityWarning, "CAMERA UPLOAD FAILURE"},
+	KindDiskLow:        {SeverityWarning, "DISK LOW"},
+	KindTamper:         {SeverityCritical, "CAMERA TAMPER"},
+	KindRecovery:       {SeverityInfo, "CAMERA RECOVERY"},
+}
+
+// Notification is a message about camera state or detected motion
+type Notification struct {
+	Kind   Kind
+	Camera string
+	Time   time.Time
+	Text   string
+	Score  float32
+	Image  string
+	// ImageURL is location of Image uploaded to target, empty when it was not uploaded
+	ImageURL    string
+	Attachments []string
+	Links       []string
+	// Resolves is kind of failure which recovery notification resolves
+	Resolves Kind
+	// Event describes motion event of event notification
+	Event *Event
+}
+
+// Event is summary of motion event
+type Event struct {
+	ID       string
+	Start    time.Time
+	End      time.Time
+	Frames   int
+	MaxScore float32
+}
+
+// New creates notification of given kind about configured camera
+func New(kind Kind, t time.Time, text string) Notification {
+	return Notification{Kind: kind, Camera: cfg.Settings.Id, Time: t, Text: text}
+}
+
+// Severity returns severity of notification kind
+func (n Notification) Severity() Severity {
+	return kinds[n.Kind].severity
+}
+
+// Subject returns title such as "CAMERA ALERT: garden"
+func (n Notification) Subject() string {
+	return fmt.Sprintf("%s: %s", kinds[n.Kind].subject, n.Camera)
+}
+
+// Files returns image followed by other attachments
+func (n Notification) Files() []string {
+	if n.Image == "" {
+		return n.Attachments
+	}
+	return append([]string{n.Image}, n.Attachments...)
+}
+
+// Notifier delivers notification over a channel such as email
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// Channel is configured notifier with routing rules
+type Channel struct {
+	Name        string
+	kinds       map[Kind]bool
+	minSeverity Severity
+	rateLimit   time.Duration
+	Notifier
+
+	mu   sync.Mutex
+	last map[limitKey]time.Time
+	jobs *worker.Queue
+}
+
+// limitKey rate limits recoveries separately per resolved failure so that one does not suppress another
+type limitKey struct {
+	kind     Kind
+	resolves Kind
+}
+
+// Channels are configured notification channels
+var Channels []*Channel
+
+var (
+	muteMu     sync.Mutex
+	mutedUntil time.Time
+)
+
+// Mute suppresses all notifications until given time, zero time unmutes
+func Mute(until time.Time) {
+	muteMu.Lock()
+	mutedUntil = until
+	muteMu.Unlock()
+}
+
+// MutedUntil returns time until which notifications are muted
+func MutedUntil() time.Time {
+	muteMu.Lock()
+	defer muteMu.Unlock()
+	return mutedUntil
+}
+
+// Init creates notification channels from configuration
+func Init() {
+	Channels = nil
+	for _, c := range cfg.Notifiers {
+		n, err := newNotifier(c)
+		if err != nil {
+			log.Fatalf("Cannot create notifier %s: %s\n", c.Name, err)
+		}
+		ch := &Channel{
+			Name:      c.Name,
+			rateLimit: time.Duration(c.RateLimit) * time.Second,
+			Notifier:  n,
+			last:      map[limitKey]time.Time{},
+			jobs:      worker.NewQueue(c.Name, cfg.Delivery.QueueSize),
+		}
+		ch.minSeverity, _ = ParseSeverity(c.MinSeverity)
+		if len(c.Kinds) > 0 {
+			ch.kinds = map[Kind]bool{}
+			for _, k := range c.Kinds {
+				ch.kinds[Kind(k)] = true
+			}
+		}
+		ch.jobs.Start(cfg.Delivery.NotifyWorkers)
+		Channels = append(Channels, ch)
+	}
+}
+
+func newNotifier(c cfg.ConfigNotifier) (Notifier, error) {
+	switch c.Type {
+	case "email":
+		return NewEmail(c)
+	case "webhook":
+		return NewWebhook(c)
+	case "telegram":
+		return NewTelegram(c), nil
+	case "slack":
+		return NewSlack(c), nil
+	case "discord":
+		return NewDiscord(c), nil
+	case "mattermost":
+		return NewMattermost(c), nil
+	case "ntfy":
+		return NewNtfy(c), nil
+	case "gotify":
+		return NewGotify(c), nil
+	}
+	return nil, fmt.Errorf("unknown notifier type %s", c.Type)
+}
+
+// accepts reports whether notification is routed to channel and not rate limited
+func (c *Channel) accepts(n Notification) bool {
+	if c.kinds != nil && !c.kinds[n.Kind] {
+		return false
+	}
+	if n.Severity() < c.minSeverity {
+		return false
+	}
+	key := limitKey{n.Kind, n.Resolves}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.rateLimit > 0 && n.Time.Sub(c.last[key]) < c.rateLimit {
+		log.Printf("Notification %s to %s is rate limited\n", n.Kind, c.Name)
+		return false
+	}
+	c.last[key] = n.Time
+	return true
+}
+
+// route returns channels accepting notification
+func route(n Notification) []*Channel {
+	if n.Time.Before(MutedUntil()) {
+		log.Printf("Notification %s is muted\n", n.Kind)
+		return nil
+	}
+	var routed []*Channel
+	for _, c := range Channels {
+		if c.accepts(n) {
+			routed = append(routed, c)
+		}
+	}
+	return routed
+}
+
+// Send delivers notification to routed channels and waits for result, it returns false when no channel accepted it
+func Send(n Notification) (bool, error) {
+	routed := route(n)
+	var failed []string
+	for _, c := range routed {
+		if err := c.Notify(n); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", c.Name, err))
+		}
+	}
+	return len(routed) > 0, joinErrors(failed)
+}
+
+// Submit queues notification to routed channels and returns immediately. Critical notifications
+// such as alerts are dropped only when queue reaches its hard limit. done is called once every
+// channel finished, it is not called when no channel accepted the notification.
+func Submit(n Notification, done func(error)) bool {
+	routed := route(n)
+	if len(routed) == 0 {
+		return false
+	}
+
+	var mu sync.Mutex
+	var failed []string
+	remaining := len(routed)
+	finish := func(name string, err error) {
+		mu.Lock()
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %s", name, err))
+		}
+		remaining--
+		last := remaining == 0
+		mu.Unlock()
+		if last && done != nil {
+			done(joinErrors(failed))
+		}
+	}
+	for _, c := range routed {
+		c := c
+		c.jobs.Push(worker.Job{
+			Urgent: n.Severity() == SeverityCritical,
+			Run:    func() { finish(c.Name, c.Notify(n)) },
+			Drop:   func() { finish(c.Name, fmt.Errorf("dropped, queue is full")) },
+		})
+	}
+	return true
+}
+
+func joinErrors(failed []string) error {
+	if len(failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%s", strings.Join(failed, "; "))
+}
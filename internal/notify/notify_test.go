@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/worker"
+)
+
+type fakeNotifier struct {
+	mu   sync.Mutex
+	got  []Kind
+	fail bool
+}
+
+func (f *fakeNotifier) Notify(n Notification) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.got = append(f.got, n.Kind)
+	if f.fail {
+		return errors.New("failed")
+	}
+	return nil
+}
+
+func channel(name string, n Notifier, minSeverity Severity, rateLimit time.Duration, kinds ...Kind) *Channel {
+	c := &Channel{Name: name, Notifier: n, minSeverity: minSeverity, rateLimit: rateLimit, last: map[limitKey]time.Time{}, jobs: worker.NewQueue(name, 10)}
+	if len(kinds) > 0 {
+		c.kinds = map[Kind]bool{}
+		for _, k := range kinds {
+			c.kinds[k] = true
+		}
+	}
+	c.jobs.Start(1)
+	return c
+}
+
+func TestSend(t *testing.T) {
+	all, alerts, critical := &fakeNotifier{}, &fakeNotifier{}, &fakeNotifier{}
+	Channels = []*Channel{
+		channel("all", all, SeverityInfo, 0),
+		channel("alerts", alerts, SeverityInfo, time.Minute, KindAlert),
+		channel("critical", critical, SeverityCritical, 0),
+	}
+	now := time.Now()
+	for _, n := range []Notification{
+		{Kind: KindStart, Time: now},
+		{Kind: KindAlert, Time: now},
+		{Kind: KindAlert, Time: now.Add(30 * time.Second)},
+		{Kind: KindUploadFailure, Time: now},
+		{Kind: KindAlert, Time: now.Add(2 * time.Minute)},
+	} {
+		if sent, err := Send(n); !sent || err != nil {
+			t.Fatalf("Send(%s) = %v, %v", n.Kind, sent, err)
+		}
+	}
+
+	check := func(name string, f *fakeNotifier, want ...Kind) {
+		if len(f.got) != len(want) {
+			t.Fatalf("%s got %v, want %v", name, f.got, want)
+		}
+		for i := range want {
+			if f.got[i] != want[i] {
+				t.Fatalf("%s got %v, want %v", name, f.got, want)
+			}
+		}
+	}
+	check("all", all, KindStart, KindAlert, KindAlert, KindUploadFailure, KindAlert)
+	check("alerts", alerts, KindAlert, KindAlert)
+	check("critical", critical, KindAlert, KindAlert, KindAlert)
+
+	// recoveries of different failures are limited separately
+	recoveries := &fakeNotifier{}
+	Channels = []*Channel{channel("recoveries", recoveries, SeverityInfo, time.Hour, KindRecovery)}
+	for _, resolves := range []Kind{KindCaptureFailure, KindDiskLow, KindDiskLow} {
+		Send(Notification{Kind: KindRecovery, Resolves: resolves, Time: now})
+	}
+	check("recoveries", recoveries, KindRecovery, KindRecovery)
+
+	Channels = []*Channel{channel("alerts", alerts, SeverityInfo, 0, KindAlert)}
+	if sent, _ := Send(Notification{Kind: KindStart, Time: now}); sent {
+		t.Fatal("start should not be routed")
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	ok, failing := &fakeNotifier{}, &fakeNotifier{fail: true}
+	Channels = []*Channel{channel("ok", ok, SeverityInfo, 0), channel("failing", failing, SeverityInfo, 0)}
+
+	done := make(chan error, 1)
+	if !Submit(Notification{Kind: KindAlert, Time: time.Now()}, func(err error) { done <- err }) {
+		t.Fatal("alert should be routed")
+	}
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "failing: failed" {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("done was not called")
+	}
+}
+
+func TestSubject(t *testing.T) {
+	n := Notification{Kind: KindCaptureFailure, Camera: "garden", Image: "a.jpg", Attachments: []string{"b.jpg"}}
+	if n.Subject() != "CAMERA CAPTURE FAILURE: garden" {
+		t.Fatalf("unexpected subject %s", n.Subject())
+	}
+	if files := n.Files(); len(files) != 2 || files[0] != "a.jpg" {
+		t.Fatalf("unexpected files %v", files)
+	}
+	if s, err := ParseSeverity("warning"); err != nil || s != SeverityWarning {
+		t.Fatalf("ParseSeverity = %v, %v", s, err)
+	}
+	if _, err := ParseSeverity("loud"); err == nil {
+		t.Fatal("unknown severity should fail")
+	}
+}
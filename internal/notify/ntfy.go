@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// ntfy priorities are 1 (min) to 5 (max)
+var (
+	ntfyBands    = []cfg.ConfigPriority{{Score: 0.5, Priority: 5}, {Score: 0.2, Priority: 4}}
+	ntfySeverity = map[Severity]int{SeverityInfo: 3, SeverityWarning: 4, SeverityCritical: 5}
+)
+
+// ntfyTags are emoji shortcodes shown by ntfy apps before title
+var ntfyTags = map[Kind]string{
+	KindStart:          "arrow_forward",
+	KindAlert:          "rotating_light",
+	KindEvent:          "movie_camera",
+	KindCaptureFailure: "warning",
+	KindUploadFailure:  "warning",
+	KindDiskLow:        "floppy_disk",
+	KindTamper:         "no_entry",
+	KindRecovery:       "white_check_mark",
+}
+
+// Ntfy publishes notifications to ntfy topic, alert image is uploaded as attachment
+type Ntfy struct {
+	url        string
+	token      string
+	user       string
+	pass       string
+	tags       []string
+	click      string
+	priorities priorities
+	retries    int
+	client     *http.Client
+}
+
+// NewNtfy creates ntfy notifier publishing to topic of server url
+func NewNtfy(c cfg.ConfigNotifier) *Ntfy {
+	return &Ntfy{
+		url:        strings.TrimSuffix(c.URL, "/") + "/" + c.Topic,
+		token:      c.Token,
+		user:       c.User,
+		pass:       c.Pass,
+		tags:       c.Tags,
+		click:      c.Click,
+		priorities: newPriorities(c.Priorities, ntfyBands, ntfySeverity),
+		retries:    c.Retries,
+		client:     httpClient(c),
+	}
+}
+
+// Notify publishes message, with image it is uploaded as attachment and message is sent in header
+func (nt *Ntfy) Notify(n Notification) error {
+	message := fmt.Sprintf("%s %s", n.Time.Format(time.RFC3339), n.Text)
+	if n.Score > 0 {
+		message += fmt.Sprintf(" (score %.2f)", n.Score)
+	}
+	tags := append([]string{ntfyTags[n.Kind]}, nt.tags...)
+	if n.Camera != "" {
+		tags = append(tags, n.Camera)
+	}
+	headers := map[string]string{
+		"Title":    header(n.Subject()),
+		"Priority": strconv.Itoa(nt.priorities.of(n)),
+		"Tags":     header(strings.Join(tags, ",")),
+	}
+	if click := clickURL(n, nt.click); click != "" {
+		headers["Click"] = click
+	}
+
+	method, body := http.MethodPost, []byte(message)
+	if file := firstImage(n.Files()); file != "" {
+		image, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		method, body = http.MethodPut, image
+		headers["Filename"] = filepath.Base(file)
+		headers["Message"] = header(message)
+	}
+	_, err := do(nt.client, nt.retries, func() (*http.Request, error) {
+		req, err := http.NewRequest(method, nt.url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if nt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+nt.token)
+		} else if nt.user != "" {
+			req.SetBasicAuth(nt.user, nt.pass)
+		}
+		return req, nil
+	})
+	return err
+}
+
+// firstImage returns the first image such as event montage following clip, ntfy attaches single file
+func firstImage(files []string) string {
+	for _, file := range files {
+		if isImage(file) {
+			return file
+		}
+	}
+	return ""
+}
+
+// header encodes value so that it is valid single line header, ntfy decodes RFC 2047 encoded words
+func header(v string) string {
+	return mime.BEncoding.Encode("utf-8", strings.ReplaceAll(v, "\n", " "))
+}
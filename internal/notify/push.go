@@ -0,0 +1,51 @@
+package notify
+
+import (
+	"sort"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// priorities maps notifications to push priority, scored notifications use the band with the highest
+// score not above notification score, others use severity. Scores below every band use severity capped
+// at priority of the lowest band so that weaker motion never gets higher priority than stronger one.
+type priorities struct {
+	bands    []cfg.ConfigPriority
+	severity map[Severity]int
+}
+
+func newPriorities(bands []cfg.ConfigPriority, defaults []cfg.ConfigPriority, severity map[Severity]int) priorities {
+	if len(bands) == 0 {
+		bands = defaults
+	}
+	sorted := append([]cfg.ConfigPriority(nil), bands...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Score > sorted[j].Score })
+	return priorities{sorted, severity}
+}
+
+func (p priorities) of(n Notification) int {
+	priority := p.severity[n.Severity()]
+	if n.Score <= 0 || len(p.bands) == 0 {
+		return priority
+	}
+	for _, b := range p.bands {
+		if n.Score >= b.Score {
+			return b.Priority
+		}
+	}
+	if lowest := p.bands[len(p.bands)-1].Priority; priority > lowest {
+		return lowest
+	}
+	return priority
+}
+
+// clickURL opens uploaded file when there is one, otherwise configured page such as camera dashboard
+func clickURL(n Notification, click string) string {
+	if n.ImageURL != "" {
+		return n.ImageURL
+	}
+	if len(n.Links) > 0 {
+		return n.Links[0]
+	}
+	return click
+}
@@ -0,0 +1,119 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+func TestPriorities(t *testing.T) {
+	p := newPriorities([]cfg.ConfigPriority{{Score: 0.1, Priority: 2}, {Score: 0.6, Priority: 5}}, ntfyBands, ntfySeverity)
+	tests := []struct {
+		n    Notification
+		want int
+	}{
+		{Notification{Kind: KindAlert, Score: 0.8}, 5},
+		{Notification{Kind: KindAlert, Score: 0.3}, 2},
+		// weaker motion never gets higher priority than stronger one
+		{Notification{Kind: KindAlert, Score: 0.05}, 2},
+		{Notification{Kind: KindRecovery, Score: 0.05}, 2},
+		{Notification{Kind: KindStart}, 3},
+		{Notification{Kind: KindUploadFailure}, 4},
+	}
+	for _, tt := range tests {
+		if got := p.of(tt.n); got != tt.want {
+			t.Errorf("priority of %s score %.2f = %d, want %d", tt.n.Kind, tt.n.Score, got, tt.want)
+		}
+	}
+
+	// alert just above default alert threshold is below default bands
+	defaults := newPriorities(nil, ntfyBands, ntfySeverity)
+	if weak, strong := defaults.of(Notification{Kind: KindAlert, Score: 0.17}), defaults.of(Notification{Kind: KindAlert, Score: 0.3}); weak > strong {
+		t.Errorf("weak alert priority %d above stronger alert priority %d", weak, strong)
+	}
+}
+
+func TestNtfy(t *testing.T) {
+	var got []*http.Request
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		got = append(got, r)
+		bodies = append(bodies, string(data))
+	}))
+	defer server.Close()
+
+	nt := NewNtfy(cfg.ConfigNotifier{URL: server.URL + "/", Topic: "camera", Token: "tk_1", Tags: []string{"home"}, Click: "https://example.com"})
+	when := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := nt.Notify(Notification{Kind: KindAlert, Camera: "garden", Time: when, Text: "diff=0.62", Score: 0.62, Image: alertImage(t)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := nt.Notify(Notification{Kind: KindRecovery, Camera: "garden", Time: when, Text: "Disk space recovered", Links: []string{"https://example.com/a.jpg"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	alert := got[0]
+	if alert.Method != http.MethodPut || alert.URL.Path != "/camera" || bodies[0] != "jpeg" || alert.Header.Get("Filename") != "alert.jpg" {
+		t.Fatalf("alert image not uploaded: %s %s %v", alert.Method, alert.URL.Path, alert.Header)
+	}
+	if alert.Header.Get("Priority") != "5" || alert.Header.Get("Tags") != "rotating_light,home,garden" ||
+		alert.Header.Get("Title") != "CAMERA ALERT: garden" || alert.Header.Get("Message") != "2021-01-01T12:00:00Z diff=0.62 (score 0.62)" ||
+		alert.Header.Get("Click") != "https://example.com" || alert.Header.Get("Authorization") != "Bearer tk_1" {
+		t.Fatalf("unexpected alert headers %v", alert.Header)
+	}
+	recovery := got[1]
+	if recovery.Method != http.MethodPost || bodies[1] != "2021-01-01T12:00:00Z Disk space recovered" ||
+		recovery.Header.Get("Priority") != "3" || recovery.Header.Get("Click") != "https://example.com/a.jpg" {
+		t.Fatalf("unexpected recovery %s %q %v", recovery.Method, bodies[1], recovery.Header)
+	}
+
+	// montage is attached although clip is listed first
+	montage := alertImage(t)
+	clip := filepath.Join(filepath.Dir(montage), "clip.mp4")
+	if err := ioutil.WriteFile(clip, []byte("mp4"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := nt.Notify(Notification{Kind: KindEvent, Camera: "garden", Time: when, Text: "event", Attachments: []string{clip, montage}}); err != nil {
+		t.Fatal(err)
+	}
+	if event := got[2]; event.Method != http.MethodPut || bodies[2] != "jpeg" || event.Header.Get("Filename") != "alert.jpg" {
+		t.Fatalf("montage not attached: %s %q %v", event.Method, bodies[2], event.Header)
+	}
+}
+
+func TestGotify(t *testing.T) {
+	var msg map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/message" || r.Header.Get("X-Gotify-Key") != "app" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		json.Unmarshal(data, &msg)
+	}))
+	defer server.Close()
+
+	g := NewGotify(cfg.ConfigNotifier{URL: server.URL, Token: "app"})
+	n := Notification{Kind: KindEvent, Camera: "garden", Time: time.Now(), Text: "event", Score: 0.3,
+		Links: []string{"https://example.com/clip.mp4", "https://example.com/montage.jpg"}}
+	if err := g.Notify(n); err != nil {
+		t.Fatal(err)
+	}
+	if msg["title"] != "CAMERA EVENT: garden" || msg["priority"] != float64(5) {
+		t.Fatalf("unexpected message %v", msg)
+	}
+	if get(msg, "extras.client::notification.click.url") != "https://example.com/clip.mp4" ||
+		get(msg, "extras.client::notification.bigImageUrl") != "https://example.com/montage.jpg" {
+		t.Fatalf("unexpected extras %v", msg["extras"])
+	}
+
+	if err := NewGotify(cfg.ConfigNotifier{URL: server.URL, Token: "wrong"}).Notify(n); err == nil {
+		t.Fatal("expected error for wrong token")
+	}
+}
@@ -0,0 +1,161 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// slackAPI is default Web API base URL used with bot token
+const slackAPI = "https://slack.com/api"
+
+// Slack posts notifications as color coded block messages. Incoming webhook can only link uploaded
+// images, bot token uploads images and threads recoveries under failures they resolve.
+type Slack struct {
+	url     string
+	token   string
+	channel string
+	retries int
+	client  *http.Client
+	threads incidents
+}
+
+// slackResponse is envelope of Web API responses
+type slackResponse struct {
+	OK        bool   `json:"ok"`
+	Error     string `json:"error"`
+	TS        string `json:"ts"`
+	Channel   string `json:"channel"`
+	UploadURL string `json:"upload_url"`
+	FileID    string `json:"file_id"`
+}
+
+// NewSlack creates slack notifier, url is incoming webhook or with token Web API base URL
+func NewSlack(c cfg.ConfigNotifier) *Slack {
+	u := strings.TrimSuffix(c.URL, "/")
+	if c.Token != "" && u == "" {
+		u = slackAPI
+	}
+	return &Slack{url: u, token: c.Token, channel: c.Channel, retries: c.Retries, client: httpClient(c)}
+}
+
+// Notify posts notification to webhook or through bot token to channel
+func (s *Slack) Notify(n Notification) error {
+	msg := slackMessage(n)
+	if s.token == "" {
+		_, err := sendJSON(s.client, s.retries, http.MethodPost, s.url, nil, msg)
+		return err
+	}
+
+	msg["channel"] = s.channel
+	thread := ""
+	if n.Kind == KindRecovery {
+		if inc, ok := s.threads.resolve(n); ok {
+			thread = inc.id
+			// recovery is shown in channel as well so that it is not missed
+			msg["reply_broadcast"] = true
+		}
+	} else if isFailure(n.Kind) {
+		thread = s.threads.opened(n.Kind)
+	}
+	if thread != "" {
+		msg["thread_ts"] = thread
+	}
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	resp, err := s.call("chat.postMessage", payload, "application/json; charset=utf-8")
+	if err != nil {
+		return err
+	}
+	if isFailure(n.Kind) {
+		s.threads.add(n, resp.TS)
+	}
+	for _, f := range n.Files() {
+		if err := s.upload(resp.Channel, thread, f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// slackMessage formats notification as attachment with severity color holding blocks
+func slackMessage(n Notification) map[string]interface{} {
+	var fs []map[string]string
+	for _, f := range fields(n) {
+		fs = append(fs, map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", f.title, f.value)})
+	}
+	blocks := []map[string]interface{}{
+		{"type": "section", "text": map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("*%s*\n%s", n.Subject(), n.Text)}},
+		{"type": "section", "fields": fs},
+	}
+	if u := imageURL(n); u != "" {
+		blocks = append(blocks, map[string]interface{}{"type": "image", "image_url": u, "alt_text": filepath.Base(u)})
+	}
+	return map[string]interface{}{
+		"text":        n.Subject(),
+		"attachments": []map[string]interface{}{{"color": color(n), "blocks": blocks}},
+	}
+}
+
+// upload shares file in channel using external upload flow of Web API
+func (s *Slack) upload(channel, thread, path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	params := url.Values{"filename": {filepath.Base(path)}, "length": {strconv.Itoa(len(data))}}
+	resp, err := s.call("files.getUploadURLExternal", []byte(params.Encode()), "application/x-www-form-urlencoded")
+	if err != nil {
+		return err
+	}
+	_, err = do(s.client, s.retries, func() (*http.Request, error) {
+		return http.NewRequest(http.MethodPost, resp.UploadURL, bytes.NewReader(data))
+	})
+	if err != nil {
+		return err
+	}
+	files, err := json.Marshal([]map[string]string{{"id": resp.FileID, "title": filepath.Base(path)}})
+	if err != nil {
+		return err
+	}
+	params = url.Values{"files": {string(files)}, "channel_id": {channel}}
+	if thread != "" {
+		params.Set("thread_ts", thread)
+	}
+	_, err = s.call("files.completeUploadExternal", []byte(params.Encode()), "application/x-www-form-urlencoded")
+	return err
+}
+
+// call invokes Web API method, errors are reported in body with status 200
+func (s *Slack) call(method string, body []byte, contentType string) (slackResponse, error) {
+	var resp slackResponse
+	data, err := do(s.client, s.retries, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, s.url+"/"+method, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer "+s.token)
+		return req, nil
+	})
+	if err != nil {
+		return resp, err
+	}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return resp, err
+	}
+	if !resp.OK {
+		return resp, fmt.Errorf("slack %s failed: %s", method, resp.Error)
+	}
+	return resp, nil
+}
@@ -0,0 +1,168 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// telegramAPI is default bot API base URL
+const telegramAPI = "https://api.telegram.org"
+
+// Telegram limits length of photo captions and text messages
+const (
+	captionLimit = 1024
+	messageLimit = 4096
+)
+
+// Telegram sends notifications to chat through bot API, images as photos and clips as animations
+type Telegram struct {
+	api     string
+	token   string
+	chatID  string
+	retries int
+	client  *http.Client
+}
+
+// telegramResponse is envelope of every bot API response
+type telegramResponse struct {
+	OK          bool            `json:"ok"`
+	Description string          `json:"description"`
+	Result      json.RawMessage `json:"result"`
+}
+
+// NewTelegram creates telegram notifier, api defaults to public bot API
+func NewTelegram(c cfg.ConfigNotifier) *Telegram {
+	api := strings.TrimSuffix(c.URL, "/")
+	if api == "" {
+		api = telegramAPI
+	}
+	return &Telegram{api: api, token: c.Token, chatID: c.ChatID, retries: c.Retries, client: httpClient(c)}
+}
+
+// Notify sends image with caption, clips as animations and other notifications as text message
+func (t *Telegram) Notify(n Notification) error {
+	text := fmt.Sprintf("%s\n%s", n.Subject(), n.Time.Format(time.RFC3339))
+	if n.Score > 0 {
+		text += fmt.Sprintf("\nscore %.2f", n.Score)
+	}
+	text += "\n" + n.Text
+	for _, link := range n.Links {
+		text += "\n" + link
+	}
+
+	files := n.Files()
+	if len(files) == 0 {
+		return t.SendMessage(t.chatID, text)
+	}
+	// caption goes with the first file only so that chat shows it once
+	for i, f := range files {
+		caption := ""
+		if i == 0 {
+			caption = text
+		}
+		if err := t.SendFile(t.chatID, f, caption); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SendMessage sends text message to chat
+func (t *Telegram) SendMessage(chatID, text string) error {
+	return t.call("sendMessage", url.Values{"chat_id": {chatID}, "text": {truncate(text, messageLimit)}}, nil)
+}
+
+// SendFile sends file to chat, videos and gifs as animations and other files as photos
+func (t *Telegram) SendFile(chatID, path, caption string) error {
+	method, field := "sendPhoto", "photo"
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".mp4", ".gif":
+		method, field = "sendAnimation", "animation"
+	}
+	params := url.Values{"chat_id": {chatID}}
+	if caption != "" {
+		params.Set("caption", truncate(caption, captionLimit))
+	}
+	return t.call(method, params, &formFile{field, path})
+}
+
+// Update is incoming message received by bot
+type Update struct {
+	ID      int64 `json:"update_id"`
+	Message *struct {
+		Date int64  `json:"date"`
+		Text string `json:"text"`
+		Chat struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"message"`
+}
+
+// Updates waits up to timeout for messages newer than offset using long polling
+func (t *Telegram) Updates(offset int64, timeout time.Duration) ([]Update, error) {
+	params := url.Values{
+		"offset":          {strconv.FormatInt(offset, 10)},
+		"timeout":         {strconv.Itoa(int(timeout.Seconds()))},
+		"allowed_updates": {`["message"]`},
+	}
+	// poll request is held open by server, it must outlive timeout
+	client := &http.Client{Timeout: timeout + t.client.Timeout}
+	data, err := t.result(client, "getUpdates", params, nil)
+	if err != nil {
+		return nil, err
+	}
+	var updates []Update
+	err = json.Unmarshal(data, &updates)
+	return updates, err
+}
+
+// call invokes bot API method, file is sent as multipart form, otherwise params are form encoded
+func (t *Telegram) call(method string, params url.Values, file *formFile) error {
+	_, err := t.result(t.client, method, params, file)
+	return err
+}
+
+// result invokes bot API method and returns its result
+func (t *Telegram) result(client *http.Client, method string, params url.Values, file *formFile) (json.RawMessage, error) {
+	body, contentType := []byte(params.Encode()), "application/x-www-form-urlencoded"
+	if file != nil {
+		var err error
+		if body, contentType, err = multipartForm(params, *file); err != nil {
+			return nil, err
+		}
+	}
+	data, err := do(client, t.retries, func() (*http.Request, error) {
+		req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/bot%s/%s", t.api, t.token, method), bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		return req, nil
+	})
+	var resp telegramResponse
+	if jerr := json.Unmarshal(data, &resp); jerr == nil && !resp.OK {
+		return nil, fmt.Errorf("telegram %s failed: %s", method, resp.Description)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.Result, nil
+}
+
+// truncate shortens text to limit of characters
+func truncate(text string, limit int) string {
+	r := []rune(text)
+	if len(r) <= limit {
+		return text
+	}
+	return string(r[:limit-1]) + "…"
+}
@@ -0,0 +1,95 @@
+package notify
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+func TestTelegram(t *testing.T) {
+	dir := t.TempDir()
+	image := filepath.Join(dir, "alert.jpg")
+	clip := filepath.Join(dir, "alert-clip.mp4")
+	for _, f := range []string{image, clip} {
+		if err := os.WriteFile(f, []byte(filepath.Base(f)), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var calls []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method := strings.TrimPrefix(r.URL.Path, "/bot123:abc/")
+		if r.FormValue("chat_id") != "42" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"ok":false,"description":"Bad Request: chat not found"}`)
+			return
+		}
+		switch method {
+		case "sendPhoto", "sendAnimation":
+			field := map[string]string{"sendPhoto": "photo", "sendAnimation": "animation"}[method]
+			f, h, err := r.FormFile(field)
+			if err != nil {
+				t.Errorf("%s without %s: %s", method, field, err)
+				break
+			}
+			data, _ := ioutil.ReadAll(f)
+			calls = append(calls, fmt.Sprintf("%s %s %s %q", method, h.Filename, data, r.FormValue("caption")))
+		case "sendMessage":
+			calls = append(calls, fmt.Sprintf("%s %q", method, r.FormValue("text")))
+		default:
+			t.Errorf("unexpected method %s", r.URL.Path)
+		}
+		fmt.Fprint(w, `{"ok":true,"result":{}}`)
+	}))
+	defer server.Close()
+
+	tg := NewTelegram(cfg.ConfigNotifier{URL: server.URL + "/", Token: "123:abc", ChatID: "42"})
+	when := time.Date(2021, 1, 1, 12, 0, 0, 0, time.UTC)
+	n := Notification{Kind: KindEvent, Camera: "garden", Time: when, Text: "event", Score: 0.5, Image: image, Attachments: []string{clip}}
+	if err := tg.Notify(n); err != nil {
+		t.Fatal(err)
+	}
+	if err := tg.Notify(Notification{Kind: KindCaptureFailure, Camera: "garden", Time: when, Text: "timeout"}); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		`sendPhoto alert.jpg alert.jpg "CAMERA EVENT: garden\n2021-01-01T12:00:00Z\nscore 0.50\nevent"`,
+		`sendAnimation alert-clip.mp4 alert-clip.mp4 ""`,
+		`sendMessage "CAMERA CAPTURE FAILURE: garden\n2021-01-01T12:00:00Z\ntimeout"`,
+	}
+	if strings.Join(calls, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("got calls\n%s\nwant\n%s", strings.Join(calls, "\n"), strings.Join(want, "\n"))
+	}
+
+	tg = NewTelegram(cfg.ConfigNotifier{URL: server.URL, Token: "123:abc", ChatID: "7"})
+	err := tg.Notify(Notification{Kind: KindStart})
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestTelegramUpdates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bot1:a/getUpdates" || r.FormValue("offset") != "5" || r.FormValue("timeout") != "1" {
+			t.Errorf("unexpected request %s %v", r.URL.Path, r.Form)
+		}
+		fmt.Fprint(w, `{"ok":true,"result":[{"update_id":5,"message":{"date":1,"text":"/status","chat":{"id":42}}},{"update_id":6}]}`)
+	}))
+	defer server.Close()
+
+	updates, err := NewTelegram(cfg.ConfigNotifier{URL: server.URL, Token: "1:a"}).Updates(5, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(updates) != 2 || updates[0].Message.Text != "/status" || updates[0].Message.Chat.ID != 42 || updates[1].Message != nil {
+		t.Fatalf("unexpected updates %+v", updates)
+	}
+}
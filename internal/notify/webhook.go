@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+// defaultWebhookBody posts notification as JSON object
+const defaultWebhookBody = `{"kind":{{json .Kind}},"severity":{{json .Severity}},"camera":{{json .Camera}},` +
+	`"time":{{json .Timestamp}},"score":{{.Score}},"text":{{json .Text}},"image":{{json .Image}},` +
+	`"imageUrl":{{json .ImageURL}}{{if .ImageBase64}},"imageBase64":{{json .ImageBase64}}{{end}}}`
+
+// Webhook sends notifications to HTTP endpoint with templated body
+type Webhook struct {
+	url     string
+	method  string
+	headers map[string]string
+	body    *template.Template
+	image   string
+	retries int
+	client  *http.Client
+}
+
+// webhookData is available to body template
+type webhookData struct {
+	Kind        string
+	Severity    string
+	Camera      string
+	Time        time.Time
+	Timestamp   string
+	Text        string
+	Score       float32
+	Image       string
+	ImageURL    string
+	ImageBase64 string
+	Links       []string
+}
+
+// NewWebhook creates webhook notifier, body defaults to JSON and method to POST
+func NewWebhook(c cfg.ConfigNotifier) (*Webhook, error) {
+	body := c.Body
+	if body == "" {
+		body = defaultWebhookBody
+	}
+	funcs := template.FuncMap{"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	}}
+	tmpl, err := template.New(c.Name).Funcs(funcs).Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	method := strings.ToUpper(c.Method)
+	if method == "" {
+		method = http.MethodPost
+	}
+	return &Webhook{
+		url:     c.URL,
+		method:  method,
+		headers: c.Headers,
+		body:    tmpl,
+		image:   c.Image,
+		retries: c.Retries,
+		client:  httpClient(c),
+	}, nil
+}
+
+// Notify renders body and sends it to endpoint, retrying failed requests
+func (w *Webhook) Notify(n Notification) error {
+	data := webhookData{
+		Kind:      string(n.Kind),
+		Severity:  n.Severity().String(),
+		Camera:    n.Camera,
+		Time:      n.Time,
+		Timestamp: n.Time.Format(time.RFC3339),
+		Text:      n.Text,
+		Score:     n.Score,
+		Image:     n.Image,
+		ImageURL:  n.ImageURL,
+		Links:     n.Links,
+	}
+	if w.image == "base64" && n.Image != "" {
+		image, err := ioutil.ReadFile(n.Image)
+		if err != nil {
+			return err
+		}
+		data.ImageBase64 = base64.StdEncoding.EncodeToString(image)
+	}
+	var body bytes.Buffer
+	if err := w.body.Execute(&body, data); err != nil {
+		return err
+	}
+
+	contentType := "application/json"
+	payload := body.Bytes()
+	if w.image == "multipart" && n.Image != "" {
+		// rendered body is sent as payload field followed by image file
+		var err error
+		if payload, contentType, err = multipartForm(url.Values{"payload": {body.String()}}, formFile{"image", n.Image}); err != nil {
+			return err
+		}
+	}
+	_, err := do(w.client, w.retries, func() (*http.Request, error) {
+		req, err := http.NewRequest(w.method, w.url, bytes.NewReader(payload))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", contentType)
+		for k, v := range w.headers {
+			// multipart boundary cannot be overridden
+			if contentType != "application/json" && strings.EqualFold(k, "Content-Type") {
+				continue
+			}
+			req.Header.Set(k, v)
+		}
+		return req, nil
+	})
+	return err
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+func TestWebhook(t *testing.T) {
+	retryDelay = time.Millisecond
+	image := filepath.Join(t.TempDir(), "alert.jpg")
+	if err := os.WriteFile(image, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	calls := 0
+	var got map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		if r.Header.Get("X-Token") != "secret" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Errorf("invalid body %s: %s", data, err)
+		}
+	}))
+	defer server.Close()
+
+	w, err := NewWebhook(cfg.ConfigNotifier{Name: "hook", URL: server.URL, Headers: map[string]string{"X-Token": "secret"}, Image: "base64", Retries: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := Notification{Kind: KindAlert, Camera: "garden", Time: time.Now(), Text: `diff="0.42"`, Score: 0.42, Image: image,
+		ImageURL: "https://example.com/alert.jpg", Links: []string{"https://example.com/clip.mp4"}}
+	if err := w.Notify(n); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 2 {
+		t.Fatalf("expected retry, got %d calls", calls)
+	}
+	if got["camera"] != "garden" || got["kind"] != "alert" || got["severity"] != "critical" || got["text"] != `diff="0.42"` || got["imageBase64"] != "anBlZw==" ||
+		got["imageUrl"] != "https://example.com/alert.jpg" {
+		t.Fatalf("unexpected payload %v", got)
+	}
+}
+
+func TestWebhookMultipart(t *testing.T) {
+	image := filepath.Join(t.TempDir(), "alert.jpg")
+	if err := os.WriteFile(image, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		if r.FormValue("payload") != "garden alert 0.50" {
+			t.Errorf("unexpected payload %q", r.FormValue("payload"))
+		}
+		f, h, err := r.FormFile("image")
+		if err != nil {
+			t.Errorf("missing image: %s", err)
+			return
+		}
+		data, _ := ioutil.ReadAll(f)
+		if h.Filename != "alert.jpg" || string(data) != "jpeg" {
+			t.Errorf("unexpected image %s %q", h.Filename, data)
+		}
+	}))
+	defer server.Close()
+
+	w, err := NewWebhook(cfg.ConfigNotifier{Name: "hook", URL: server.URL, Method: "put", Body: "{{.Camera}} {{.Kind}} {{printf \"%.2f\" .Score}}", Image: "multipart"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Notify(Notification{Kind: KindAlert, Camera: "garden", Score: 0.5, Image: image}); err != nil {
+		t.Fatal(err)
+	}
+
+	// client errors are not retried
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusBadRequest) })
+	if err := w.Notify(Notification{Kind: KindStart}); err == nil {
+		t.Fatal("expected error")
+	}
+}
@@ -0,0 +1,26 @@
+package pathtmpl
+
+import (
+	"bytes"
+	"text/template"
+	"time"
+
+	"github.com/lestrrat-go/strftime"
+)
+
+// Expand expands strftime verbs of given time and template fields of path template
+func Expand(pathTemplate string, t time.Time, data interface{}) (string, error) {
+	path, err := strftime.Format(pathTemplate, t)
+	if err != nil {
+		return "", err
+	}
+	tmpl, err := template.New("Path").Parse(path)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
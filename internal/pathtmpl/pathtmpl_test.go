@@ -0,0 +1,21 @@
+package pathtmpl
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	when := time.Date(2021, 3, 14, 7, 5, 3, 0, time.Local)
+	data := struct{ Camera, Seq string }{"garden", "0001"}
+
+	path, err := Expand("{{.Camera}}/%Y/%m/%d/%H%M%S-{{.Seq}}.jpg", when, data)
+	if err != nil || path != "garden/2021/03/14/070503-0001.jpg" {
+		t.Errorf("unexpected path %q, %v", path, err)
+	}
+	for _, invalid := range []string{"%Y/%Q-{{.Seq}}.jpg", "{{.Day}}/{{.Seq}}.jpg", "{{.Seq.jpg"} {
+		if _, err := Expand(invalid, when, data); err == nil {
+			t.Errorf("%s: expected error", invalid)
+		}
+	}
+}
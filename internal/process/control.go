@@ -0,0 +1,87 @@
+package process
+
+import (
+	"io/ioutil"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/system"
+)
+
+// disarmed suppresses alerts and event notifications, frames are still captured, stored and uploaded
+var disarmed int32
+
+// Frame is a kept frame reported by remote commands
+type Frame struct {
+	Path  string
+	Time  time.Time
+	Score float32
+}
+
+var (
+	lastMu    sync.Mutex
+	lastFrame Frame
+)
+
+// frameHooks are called with every compared frame
+var frameHooks []func(f Frame, alert bool)
+
+// OnFrame registers function called with every compared frame and whether alert was sent for it,
+// it is called from capture loop and must not block
+func OnFrame(fn func(f Frame, alert bool)) {
+	frameHooks = append(frameHooks, fn)
+}
+
+func frameCompared(f Frame, alert bool) {
+	for _, fn := range frameHooks {
+		fn(f, alert)
+	}
+}
+
+// Arm enables or disables alerts
+func Arm(armed bool) {
+	var v int32
+	if !armed {
+		v = 1
+	}
+	atomic.StoreInt32(&disarmed, v)
+}
+
+// Armed reports whether alerts are enabled
+func Armed() bool {
+	return atomic.LoadInt32(&disarmed) == 0
+}
+
+// LastFrame returns the last kept frame, path is empty when no frame was kept yet
+func LastFrame() Frame {
+	lastMu.Lock()
+	defer lastMu.Unlock()
+	return lastFrame
+}
+
+func setLastFrame(f Frame) {
+	lastMu.Lock()
+	lastFrame = f
+	lastMu.Unlock()
+}
+
+// Snapshot captures current frame into temporary file which caller removes
+func Snapshot() (string, error) {
+	f, err := ioutil.TempFile("", "watchdog-snapshot-*.jpg")
+	if err != nil {
+		return "", err
+	}
+	f.Close()
+	command, err := system.GetCaptureCommand(f.Name())
+	if err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	if err := system.ExecuteCommand(command, 10*time.Second); err != nil {
+		os.Remove(f.Name())
+		return "", err
+	}
+	return f.Name(), nil
+}
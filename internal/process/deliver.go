@@ -0,0 +1,116 @@
+package process
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/index"
+	"github.com/kornelkabele/watchdog/internal/notify"
+	"github.com/kornelkabele/watchdog/internal/upload"
+)
+
+// frameRecord is index record of kept frame updated as delivery workers finish
+type frameRecord struct {
+	mu    sync.Mutex
+	frame index.Frame
+}
+
+// update changes record and writes it to index
+func (r *frameRecord) update(fn func(f *index.Frame)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.frame)
+	addToIndex(r.frame)
+}
+
+// save writes record to index
+func (r *frameRecord) save() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	addToIndex(r.frame)
+}
+
+// submitAlert sends alert about kept frame in background, imageURL links uploaded frame when there is one
+func submitAlert(record *frameRecord, t time.Time, sidx float32, imageURL string) {
+	imageName := record.frame.Path
+	n := notify.New(notify.KindAlert, t, fmt.Sprintf("diff=%0.2f", sidx))
+	n.Score = sidx
+	n.Image = imageName
+	n.ImageURL = imageURL
+	hold(imageName)
+	routed := notify.Submit(n, func(err error) {
+		release(imageName)
+		record.update(func(f *index.Frame) {
+			f.Actions = append(f.Actions, "email")
+			f.Email = index.StatusOK
+			if err != nil {
+				f.Email = index.StatusFailed
+			}
+		})
+		if err != nil {
+			log.Printf("Failed to send alert (%s, sim=%.2f): %s\n", imageName, sidx, err)
+		} else {
+			log.Printf("Alert success (%s, sim=%.2f)\n", imageName, sidx)
+		}
+	})
+	if !routed {
+		release(imageName)
+	}
+}
+
+// alertURLWait bounds how long alert of uploaded frame waits for link of the upload
+var alertURLWait = 5 * time.Second
+
+// delayAlert sends alert about uploaded frame without link once alertURLWait passes,
+// returned function sends it earlier with link of finished upload, alert is sent only once
+func delayAlert(record *frameRecord, t time.Time, sidx float32) func(imageURL string) {
+	var once sync.Once
+	send := func(imageURL string) {
+		once.Do(func() { submitAlert(record, t, sidx, imageURL) })
+	}
+	timer := time.AfterFunc(alertURLWait, func() { send("") })
+	return func(imageURL string) {
+		timer.Stop()
+		send(imageURL)
+	}
+}
+
+// uploadedURL returns location of the first successful upload
+func uploadedURL(results []upload.Result) string {
+	for _, r := range results {
+		if r.Err == nil && r.URL != "" {
+			return r.URL
+		}
+	}
+	return ""
+}
+
+// uploadFile uploads file to targets, logs results and notifies about failures
+func uploadFile(f upload.File) []upload.Result {
+	results := upload.Upload(f)
+	logResults(f, results)
+	return results
+}
+
+// logResults logs upload results and notifies about failures that are not retried
+func logResults(f upload.File, results []upload.Result) {
+	for _, r := range results {
+		if r.Err == nil {
+			log.Printf("Upload to %s success (%s, sim=%.2f)\n", r.Target, f.Path, f.Score)
+			continue
+		}
+		log.Printf("Failed to upload to %s (%s, sim=%.2f): %s\n", r.Target, f.Path, f.Score, r.Err)
+		if r.Queued || r.Err == upload.ErrDropped {
+			// queued uploads are retried when connectivity returns, dropped ones are routine frames
+			continue
+		}
+		n := notify.New(notify.KindUploadFailure, time.Now(), fmt.Sprintf("Failed to upload to %s: %s", r.Target, r.Err))
+		notify.Submit(n, func(err error) {
+			if err != nil {
+				log.Printf("Failed to send upload failure: %s\n", err)
+			}
+		})
+	}
+}
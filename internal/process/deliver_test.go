@@ -0,0 +1,39 @@
+package process
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/index"
+	"github.com/kornelkabele/watchdog/internal/notify"
+)
+
+func TestDelayAlert(t *testing.T) {
+	dir, _, kinds := eventSetup(t)
+	alertURLWait = 50 * time.Millisecond
+	t.Cleanup(func() { alertURLWait = 5 * time.Second })
+	frames := writeFrames(t, dir, "0007-0001.jpg", "0007-0002.jpg")
+
+	// slow upload does not hold alert back, its late link does not send it again
+	send := delayAlert(&frameRecord{frame: index.Frame{Path: frames[0]}}, time.Now(), 0.3)
+	select {
+	case kind := <-kinds:
+		if kind != string(notify.KindAlert) {
+			t.Errorf("unexpected notification %s", kind)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("alert waited for upload")
+	}
+	send("https://example.com/0007-0001.jpg")
+
+	// upload finished in time sends alert at once
+	send = delayAlert(&frameRecord{frame: index.Frame{Path: frames[1]}}, time.Now(), 0.3)
+	send("https://example.com/0007-0002.jpg")
+	if kind := <-kinds; kind != string(notify.KindAlert) {
+		t.Errorf("unexpected notification %s", kind)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if len(kinds) != 0 {
+		t.Errorf("alert sent twice: %s", <-kinds)
+	}
+}
@@ -0,0 +1,83 @@
+package process
+
+import (
+	"log"
+	"os"
+	"sync"
+
+	"github.com/kornelkabele/watchdog/internal/crypt"
+	"github.com/kornelkabele/watchdog/internal/storage"
+)
+
+// Stored image is encrypted once it is no longer used as reference and every delivery
+// and event reading it finished, so that uploads and notifications always read plain image.
+var (
+	encryptMu sync.Mutex
+	// readers counts deliveries and events still reading each stored image
+	readers = map[string]int{}
+	// released are images no longer used as reference waiting for their readers
+	released = map[string]bool{}
+)
+
+// hold keeps stored image on disk and plain until release is called for it
+func hold(path string) {
+	storage.Pin(path)
+	if !crypt.Local() {
+		return
+	}
+	encryptMu.Lock()
+	readers[path]++
+	encryptMu.Unlock()
+}
+
+// release ends hold of stored image, image already released as reference is encrypted by its last reader
+func release(path string) {
+	storage.Unpin(path)
+	if !crypt.Local() {
+		return
+	}
+	encryptMu.Lock()
+	readers[path]--
+	encrypt := readers[path] <= 0 && released[path]
+	if readers[path] <= 0 {
+		delete(readers, path)
+	}
+	if encrypt {
+		delete(released, path)
+	}
+	encryptMu.Unlock()
+	if encrypt {
+		encryptImage(path)
+	}
+}
+
+// scheduleEncryption encrypts stored image no longer used as reference when local encryption is enabled,
+// image still read by delivery is encrypted once it is released
+func scheduleEncryption(path string) {
+	if !crypt.Local() {
+		return
+	}
+	encryptMu.Lock()
+	encrypt := readers[path] == 0
+	if !encrypt {
+		released[path] = true
+	}
+	encryptMu.Unlock()
+	if encrypt {
+		encryptImage(path)
+	}
+}
+
+// encryptImage encrypts stored image along with its sidecar, sidecar is uploaded before image is released
+func encryptImage(path string) {
+	if err := crypt.EncryptFile(path); err != nil {
+		log.Printf("Failed to encrypt image (%s): %s\n", path, err)
+	}
+	meta := sidecarName(path)
+	if _, err := os.Stat(meta); err != nil {
+		return
+	}
+	if err := crypt.EncryptFile(meta); err != nil {
+		log.Printf("Failed to encrypt sidecar (%s): %s\n", meta, err)
+	}
+}
@@ -0,0 +1,60 @@
+package process
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"filippo.io/age"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/crypt"
+)
+
+func TestEncryptAfterRelease(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Encryption = cfg.ConfigEncryption{Recipients: []string{identity.Recipient().String()}, Local: true}
+	if err := crypt.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cfg.Encryption = cfg.ConfigEncryption{}
+		crypt.Init()
+	})
+
+	dir := t.TempDir()
+	image := filepath.Join(dir, "0007-0001.jpg")
+	idle := filepath.Join(dir, "0007-0002.jpg")
+	for _, name := range []string{image, idle} {
+		if err := ioutil.WriteFile(name, []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// image read by upload and alert stays plain after it stops being reference
+	hold(image)
+	hold(image)
+	scheduleEncryption(image)
+	release(image)
+	if crypt.IsEncrypted(image) {
+		t.Fatal("image encrypted while alert still reads it")
+	}
+	release(image)
+	if !crypt.IsEncrypted(image) {
+		t.Fatal("image not encrypted once released")
+	}
+
+	// image nobody reads is encrypted at once along with its sidecar
+	if err := ioutil.WriteFile(sidecarName(idle), []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	scheduleEncryption(idle)
+	if !crypt.IsEncrypted(idle) || !crypt.IsEncrypted(sidecarName(idle)) {
+		t.Fatal("idle image or its sidecar not encrypted")
+	}
+	if len(readers) != 0 || len(released) != 0 {
+		t.Errorf("pending encryption left behind: %v, %v", readers, released)
+	}
+}
@@ -0,0 +1,234 @@
+package process
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	img "github.com/kornelkabele/watchdog/internal/image"
+	"github.com/kornelkabele/watchdog/internal/index"
+	"github.com/kornelkabele/watchdog/internal/notify"
+	"github.com/kornelkabele/watchdog/internal/upload"
+	"github.com/kornelkabele/watchdog/internal/video"
+)
+
+// frame is a kept image belonging to an event
+type frame struct {
+	path string
+	time time.Time
+	sidx float32
+}
+
+// event groups kept frames from the first frame above upload threshold until the scene calms down
+type event struct {
+	start     time.Time
+	last      time.Time
+	frames    []frame
+	maxSidx   float32
+	recording chan string
+}
+
+var current *event
+
+// trackEvent adds kept image to the current event or starts a new one and returns event id
+func trackEvent(imageName string, t time.Time, sidx float32) string {
+	if current == nil {
+		if sidx <= cfg.Settings.UploadThreshold {
+			return ""
+		}
+		current = &event{start: t}
+		if cfg.Clip.Enabled && cfg.Clip.Source == "rtsp" {
+			current.recording = startRecording(clipName(imageName))
+		}
+		log.Printf("Event started (%s, sim=%.2f)\n", imageName, sidx)
+	}
+
+	// frames stay plain until clip and montage are built from them
+	hold(imageName)
+	current.frames = append(current.frames, frame{imageName, t, sidx})
+	if sidx > cfg.Settings.UploadThreshold {
+		current.last = t
+	}
+	if sidx > current.maxSidx {
+		current.maxSidx = sidx
+	}
+	return index.EventID(current.start)
+}
+
+// checkEventEnd finishes the current event when no frame triggered it for eventGap seconds
+func checkEventEnd(t time.Time) {
+	if current == nil || t.Sub(current.last).Seconds() <= float64(cfg.Settings.EventGap) {
+		return
+	}
+	e := current
+	current = nil
+	log.Printf("Event finished (%d frames, max sim=%.2f)\n", len(e.frames), e.maxSidx)
+	go finishEvent(e)
+}
+
+// clipName derives clip file name from the image that triggered the event
+func clipName(imageName string) string {
+	return strings.TrimSuffix(imageName, filepath.Ext(imageName)) + "-clip.mp4"
+}
+
+// montageName derives montage file name from the image that triggered the event
+func montageName(imageName string) string {
+	return strings.TrimSuffix(imageName, filepath.Ext(imageName)) + "-montage.jpg"
+}
+
+// startRecording records clip from camera stream in background, the channel receives clip path or empty string on failure
+func startRecording(clip string) chan string {
+	done := make(chan string, 1)
+	go func() {
+		if err := video.Record(clip, video.ClipEncoding(cfg.Clip.RecordCmd)); err != nil {
+			log.Printf("Failed to record clip (%s): %s\n", clip, err)
+			done <- ""
+			return
+		}
+		done <- clip
+	}()
+	return done
+}
+
+// buildClip returns event clip, either recorded from stream or assembled from stored frames
+func (e *event) buildClip() string {
+	if e.recording != nil {
+		return <-e.recording
+	}
+
+	var frames []string
+	for _, f := range e.existingFrames() {
+		frames = append(frames, f.path)
+	}
+	clip := clipName(e.frames[0].path)
+	if err := video.FromFrames(frames, clip, video.ClipEncoding(cfg.Clip.FramesCmd)); err != nil {
+		log.Printf("Failed to create clip (%s): %s\n", clip, err)
+		return ""
+	}
+	return clip
+}
+
+// buildMontage tiles event frames into a single contact sheet labeled with time and similarity index
+func (e *event) buildMontage() string {
+	frames := e.existingFrames()
+	if n := cfg.Montage.MaxTiles; len(frames) > n {
+		sampled := make([]frame, n)
+		for i := range sampled {
+			sampled[i] = frames[i*len(frames)/n]
+		}
+		frames = sampled
+	}
+
+	tiles := make([]img.Tile, len(frames))
+	for i, f := range frames {
+		tiles[i] = img.Tile{Path: f.path, Label: fmt.Sprintf("%s %.2f", f.time.Format("15:04:05"), f.sidx)}
+	}
+	montage := montageName(e.frames[0].path)
+	sheet, err := img.Montage(tiles, cfg.Montage.Columns, cfg.Montage.TileWidth)
+	if err == nil {
+		err = imaging.Save(sheet, montage, imaging.JPEGQuality(85))
+	}
+	if err != nil {
+		log.Printf("Failed to create montage (%s): %s\n", montage, err)
+		return ""
+	}
+	return montage
+}
+
+// existingFrames skips frames that were already removed from local directory
+func (e *event) existingFrames() []frame {
+	var frames []frame
+	for _, f := range e.frames {
+		if _, err := os.Stat(f.path); err == nil {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+// finishEvent produces event artifacts, uploads them and sends event summary
+func finishEvent(e *event) {
+	record := index.Event{
+		ID:       index.EventID(e.start),
+		Camera:   cfg.Settings.Id,
+		Start:    e.start,
+		End:      e.last,
+		Frames:   len(e.frames),
+		MaxScore: e.maxSidx,
+	}
+	defer func() {
+		if err := index.PutEvent(record); err != nil {
+			log.Printf("Failed to add event to index (%s): %s\n", record.ID, err)
+		}
+	}()
+	// frames are released before event is recorded as finished
+	defer func() {
+		for _, f := range e.frames {
+			release(f.path)
+		}
+	}()
+
+	var artifacts, attachments, links []string
+	if cfg.Clip.Enabled {
+		if clip := e.buildClip(); clip != "" {
+			record.Actions = append(record.Actions, "clip")
+			artifacts = append(artifacts, clip)
+			if fi, err := os.Stat(clip); err == nil && fi.Size() <= int64(cfg.Clip.MaxSize)*1024 {
+				attachments = append(attachments, clip)
+			}
+		}
+	}
+	if cfg.Montage.Enabled {
+		if montage := e.buildMontage(); montage != "" {
+			record.Actions = append(record.Actions, "montage")
+			artifacts = append(artifacts, montage)
+			attachments = append(attachments, montage)
+		}
+	}
+	if len(artifacts) == 0 {
+		return
+	}
+
+	for _, artifact := range artifacts {
+		results := uploadFile(upload.File{Path: artifact, Time: e.start, Score: e.maxSidx})
+		if len(results) > 0 && record.Upload == index.StatusNone {
+			record.Actions = append(record.Actions, "upload")
+			record.Upload = index.StatusOK
+		}
+		if upload.Failed(results) != nil {
+			record.Upload = index.StatusFailed
+		}
+		for _, r := range results {
+			if r.Err == nil && r.URL != "" {
+				links = append(links, r.URL)
+			}
+		}
+	}
+
+	if e.maxSidx <= cfg.Settings.EmailThreshold || !Armed() {
+		return
+	}
+	n := notify.New(notify.KindEvent, e.start, fmt.Sprintf("event until %s frames=%d max diff=%0.2f",
+		e.last.Format(time.RFC3339), len(e.frames), e.maxSidx))
+	n.Score = e.maxSidx
+	n.Attachments = attachments
+	n.Links = links
+	n.Event = &notify.Event{ID: record.ID, Start: e.start, End: e.last, Frames: len(e.frames), MaxScore: e.maxSidx}
+	sent, err := notify.Send(n)
+	if !sent {
+		return
+	}
+	record.Actions = append(record.Actions, "email")
+	record.Email = index.StatusOK
+	if err != nil {
+		record.Email = index.StatusFailed
+		log.Printf("Failed to send event notification (%s): %s\n", e.frames[0].path, err)
+	} else {
+		log.Printf("Event notification success (%s)\n", e.frames[0].path)
+	}
+}
@@ -0,0 +1,214 @@
+package process
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/index"
+	"github.com/kornelkabele/watchdog/internal/local"
+	"github.com/kornelkabele/watchdog/internal/notify"
+	"github.com/kornelkabele/watchdog/internal/upload"
+)
+
+// eventSetup configures index, local upload target and webhook notifier, it returns image directory,
+// target directory and channel receiving kinds of sent notifications
+func eventSetup(t *testing.T) (string, string, chan string) {
+	dir := t.TempDir()
+	cfg.Settings.Id = "garden"
+	cfg.Settings.ImageDir = filepath.Join(dir, "images")
+	cfg.Settings.IndexFile = filepath.Join(dir, "index.db")
+	cfg.Settings.UploadThreshold = 0.1
+	cfg.Settings.EmailThreshold = 0.16
+	cfg.Settings.EventGap = 10
+	cfg.Clip = cfg.ConfigClip{}
+	cfg.Montage = cfg.ConfigMontage{}
+	if err := os.MkdirAll(cfg.Settings.ImageDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := index.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+
+	remote := filepath.Join(dir, "remote")
+	upload.Targets = []*upload.Target{{Name: "nas", PathTemplate: "{{.Name}}", Uploader: local.New(cfg.ConfigTarget{Dir: remote})}}
+	t.Cleanup(func() { upload.Targets = nil })
+
+	kinds := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		kind, _ := body["kind"].(string)
+		kinds <- kind
+	}))
+	t.Cleanup(server.Close)
+	cfg.Notifiers = []cfg.ConfigNotifier{{Name: "hook", Type: "webhook", URL: server.URL}}
+	cfg.Delivery = cfg.ConfigDelivery{QueueSize: 10, NotifyWorkers: 1}
+	notify.Init()
+	t.Cleanup(func() { notify.Channels = nil })
+
+	current = nil
+	return cfg.Settings.ImageDir, remote, kinds
+}
+
+// writeFrames stores frames with given names in directory
+func writeFrames(t *testing.T, dir string, names ...string) []string {
+	var paths []string
+	for _, name := range names {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// waitEvent waits until finished event is written to index
+func waitEvent(t *testing.T) index.Event {
+	for i := 0; i < 500; i++ {
+		events, err := index.Events(index.Filter{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) > 0 {
+			return events[0]
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("event was not finished")
+	return index.Event{}
+}
+
+func TestEventClip(t *testing.T) {
+	dir, remote, kinds := eventSetup(t)
+	// frame list stands in for video so that ffmpeg is not needed
+	cfg.Clip = cfg.ConfigClip{Enabled: true, Source: "frames", FrameRate: 2, MaxSize: 1024, FramesCmd: "cp {{.List}} {{.Clip}}"}
+	frames := writeFrames(t, dir, "0007-0001.jpg", "0007-0002.jpg", "0007-0003.jpg", "0007-0004.jpg")
+	start := time.Date(2021, 3, 14, 7, 0, 0, 0, time.Local)
+
+	// calm frame does not start event, following calm frames belong to started one
+	if id := trackEvent(frames[0], start, 0.05); id != "" || current != nil {
+		t.Fatalf("event started below upload threshold: %q", id)
+	}
+	id := trackEvent(frames[1], start.Add(time.Second), 0.3)
+	if id != index.EventID(start.Add(time.Second)) {
+		t.Fatalf("unexpected event id %q", id)
+	}
+	if got := trackEvent(frames[2], start.Add(5*time.Second), 0.05); got != id {
+		t.Fatalf("calm frame started another event %q", got)
+	}
+	// removed frame is left out of clip
+	trackEvent(frames[3], start.Add(6*time.Second), 0.2)
+	os.Remove(frames[3])
+
+	// event gap is measured from the last frame above upload threshold
+	checkEventEnd(start.Add(16 * time.Second))
+	if current == nil {
+		t.Fatal("event finished within event gap")
+	}
+	checkEventEnd(start.Add(17 * time.Second))
+	if current != nil {
+		t.Fatal("event did not finish after event gap")
+	}
+
+	e := waitEvent(t)
+	if e.ID != id || e.Frames != 3 || e.MaxScore != 0.3 || !e.End.Equal(start.Add(6*time.Second)) {
+		t.Errorf("unexpected event record %+v", e)
+	}
+	if strings.Join(e.Actions, ",") != "clip,upload,email" || e.Upload != index.StatusOK || e.Email != index.StatusOK {
+		t.Errorf("unexpected event actions %v, upload %q, email %q", e.Actions, e.Upload, e.Email)
+	}
+	select {
+	case kind := <-kinds:
+		if kind != string(notify.KindEvent) {
+			t.Errorf("unexpected notification %s", kind)
+		}
+	default:
+		t.Error("event notification was not sent")
+	}
+
+	list, err := ioutil.ReadFile(filepath.Join(remote, "0007-0002-clip.mp4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(list), frames[1]) || !strings.Contains(string(list), frames[2]) || strings.Contains(string(list), frames[3]) {
+		t.Errorf("unexpected clip frames %s", list)
+	}
+}
+
+func TestEventBelowAlertThreshold(t *testing.T) {
+	dir, _, kinds := eventSetup(t)
+	cfg.Clip = cfg.ConfigClip{Enabled: true, Source: "frames", FrameRate: 2, MaxSize: 1024, FramesCmd: "cp {{.List}} {{.Clip}}"}
+	frames := writeFrames(t, dir, "0007-0001.jpg")
+	start := time.Now()
+
+	trackEvent(frames[0], start, 0.12)
+	e := current
+	current = nil
+	finishEvent(e)
+
+	events, err := index.Events(index.Filter{})
+	if err != nil || len(events) != 1 {
+		t.Fatalf("expected event record, got %v, %v", events, err)
+	}
+	if strings.Join(events[0].Actions, ",") != "clip,upload" || events[0].Email != index.StatusNone {
+		t.Errorf("event below alert threshold was notified: %+v", events[0])
+	}
+	if len(kinds) != 0 {
+		t.Errorf("unexpected notification %s", <-kinds)
+	}
+}
+
+func TestEventMontage(t *testing.T) {
+	dir, remote, kinds := eventSetup(t)
+	cfg.Montage = cfg.ConfigMontage{Enabled: true, Columns: 2, TileWidth: 40, MaxTiles: 4}
+	start := time.Now()
+	shades := []uint8{0, 50, 100, 150, 200, 250}
+	for i, shade := range shades {
+		path := filepath.Join(dir, fmt.Sprintf("0007-%04d.jpg", i+1))
+		if err := imaging.Save(imaging.New(80, 60, color.Gray{shade}), path); err != nil {
+			t.Fatal(err)
+		}
+		trackEvent(path, start.Add(time.Duration(i)*time.Second), 0.3)
+	}
+	e := current
+	current = nil
+	finishEvent(e)
+
+	events, err := index.Events(index.Filter{})
+	if err != nil || len(events) != 1 || strings.Join(events[0].Actions, ",") != "montage,upload,email" {
+		t.Fatalf("unexpected event records %v, %v", events, err)
+	}
+	if kind := <-kinds; kind != string(notify.KindEvent) {
+		t.Errorf("unexpected notification %s", kind)
+	}
+
+	sheet, err := imaging.Open(filepath.Join(remote, "0007-0001-montage.jpg"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := sheet.Bounds(); b.Dx() != 80 || b.Dy() != 60 {
+		t.Fatalf("expected 2x2 grid of 40x30 tiles, got %v", b)
+	}
+	// six frames are sampled evenly into four tiles
+	for i, want := range []uint8{0, 50, 150, 200} {
+		x, y := (i%2)*40+20, (i/2)*30+5
+		// JPEG compression shifts shades slightly
+		got := color.GrayModel.Convert(sheet.At(x, y)).(color.Gray).Y
+		if d := int(got) - int(want); d < -10 || d > 10 {
+			t.Errorf("tile %d shade %d, want %d", i, got, want)
+		}
+	}
+}
@@ -4,21 +4,25 @@ import (
 	"fmt"
 	"log"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/kornelkabele/watchdog/internal/cfg"
-	"github.com/kornelkabele/watchdog/internal/email"
-	"github.com/kornelkabele/watchdog/internal/file"
-	ftp "github.com/kornelkabele/watchdog/internal/ftp"
 	img "github.com/kornelkabele/watchdog/internal/image"
+	"github.com/kornelkabele/watchdog/internal/index"
+	"github.com/kornelkabele/watchdog/internal/notify"
+	"github.com/kornelkabele/watchdog/internal/storage"
 	"github.com/kornelkabele/watchdog/internal/system"
+	"github.com/kornelkabele/watchdog/internal/upload"
 )
 
 var (
-	lastWeekdayHour string
-	lastImage       string
-	lastAlert       time.Time
+	lastImage string
+	lastAlert time.Time
+	lastKept  time.Time
+	// captureFailing is set while capture fails, captureNotified once the failure was notified
+	captureFailing  bool
+	captureNotified bool
+	tampered        bool
 )
 
 func init() {
@@ -29,62 +33,61 @@ func init() {
 func Process() {
 	// update time
 	currentTime := time.Now()
-	weekday := fmt.Sprintf("%02d", currentTime.Weekday())
-	weekdayHour := fmt.Sprintf("%s%02d", weekday, currentTime.Hour())
-
-	// update directory
-	imagePath := filepath.Join(cfg.Settings.ImageDir, weekday)
-	allImagesMask := filepath.Join(imagePath, weekdayHour+"-*.jpg")
-	err := file.CreateDir(imagePath)
-	if err != nil {
-		log.Fatalf("Cannot create directory: %s\n", err)
-	}
-	if weekdayHour != lastWeekdayHour {
-		if len(lastWeekdayHour) > 0 {
-			file.RemoveContents(allImagesMask)
-		}
-		lastWeekdayHour = weekdayHour
-	}
+	checkEventEnd(currentTime)
 
 	// capture new image
-	numFiles, err := file.CountFiles(allImagesMask)
+	imageName, err := storage.NextImagePath(currentTime)
 	if err != nil {
-		log.Printf("Failed to count number of files in directory: %s\n", err)
+		log.Printf("Failed to create image path: %s\n", err)
+		return
 	}
-	imageName := filepath.Join(imagePath, fmt.Sprintf("%s-%04d.jpg", weekdayHour, 1+numFiles))
 	captureCommand, err := system.GetCaptureCommand(imageName)
 	if err != nil {
 		fmt.Printf("Failed to create capture command: %s\n", err)
 	}
 	err = retry(5, 1*time.Second, func() error { return system.ExecuteCommand(captureCommand, 10*time.Second) })
 	if err != nil {
-		log.Printf("Failed to capture image: %s\n", err)
-		err = email.SendEmail(fmt.Sprintf("CAMERA CAPTURE FAILURE: %s", cfg.Settings.Id),
-			fmt.Sprintf("%s Failed to capture camera: %s", time.Now().Format(time.RFC3339), err),
-			nil)
-		if err != nil {
-			log.Printf("Failed to send email failure: %s\n", err)
-		}
+		captureFailed(currentTime, err)
 		return
 	}
+	captureRecovered(currentTime)
 
 	// keep if there is no reference
 	if len(lastImage) == 0 {
-		lastImage = imageName
+		setReference(imageName)
 		return
 	}
 
 	// compute similarity index
 	sidx, err := img.ImageSimilarityIndexFile(imageName, lastImage, cfg.Settings.Sensitivity)
 	if err != nil {
+		// reference may have been removed by retention, start over from the new image
 		log.Printf("Failed to calculate similarity index: %s\n", err)
+		setReference(imageName)
 		return
 	}
 
 	fmt.Printf("Similarity index = %.2f (%s)\n", sidx, imageName)
+	alert := false
+	defer func() { frameCompared(Frame{imageName, currentTime, sidx}, alert) }()
+
+	// store alert images only while disk space is low
+	keepThreshold := cfg.Settings.KeepThreshold
+	if storage.AlertOnly() {
+		keepThreshold = cfg.Settings.EmailThreshold
+	}
+
+	// keep idle snapshot so that quiet hours still appear in timelapse
+	if sidx < keepThreshold && !storage.AlertOnly() && isIdleSnapshot(currentTime) {
+		lastKept = currentTime
+		writeSidecar(imageName, lastImage, currentTime, sidx, nil)
+		addToIndex(index.Frame{Camera: cfg.Settings.Id, Path: imageName, Time: currentTime, Score: sidx, Actions: []string{"idle"}})
+		scheduleEncryption(imageName)
+		return
+	}
 
 	// remove from local directory if too similar
-	if sidx < cfg.Settings.KeepThreshold {
+	if sidx < keepThreshold {
 		err = os.Remove(imageName)
 		if err != nil {
 			log.Println(err)
@@ -92,34 +95,148 @@ func Process() {
 		return
 	}
 
-	lastImage = imageName
+	reference := lastImage
+	setReference(imageName)
+	lastKept = currentTime
+	setLastFrame(Frame{imageName, currentTime, sidx})
+	scheduleEncryption(reference)
+	record := &frameRecord{frame: index.Frame{Camera: cfg.Settings.Id, Path: imageName, Time: currentTime, Score: sidx, Actions: []string{"kept"}}}
+	record.frame.Event = trackEvent(imageName, currentTime, sidx)
+	record.frame.Alert = sidx > cfg.Settings.EmailThreshold
 
-	// upload to FTP
-	if sidx > cfg.Settings.UploadThreshold {
-		err = ftp.UploadFTP(imageName, weekday)
-		if err != nil {
-			log.Printf("Failed to upload to FTP (%s, sim=%.2f): %s\n", imageName, sidx, err)
-			err = email.SendEmail(fmt.Sprintf("CAMERA FTP FAILURE: %s", cfg.Settings.Id),
-				fmt.Sprintf("%s Failed to upload to FTP: %s", time.Now().Format(time.RFC3339), err),
-				nil)
+	// tamper is notified once until scene settles, it replaces alert of the frame
+	tamper := cfg.Settings.TamperThreshold > 0 && sidx >= cfg.Settings.TamperThreshold
+	notifyTamper := tamper && !tampered && Armed()
+	tampered = tamper
+
+	uploading := sidx > cfg.Settings.UploadThreshold
+	alert = sidx > cfg.Settings.EmailThreshold && currentTime.Sub(lastAlert).Seconds() > float64(cfg.Settings.EmailInterval) && Armed() && !tamper
+	fired := []string{"keep"}
+	if uploading {
+		fired = append(fired, "upload")
+	}
+	if alert {
+		fired = append(fired, "email")
+	}
+	if notifyTamper {
+		fired = append(fired, "tamper")
+	}
+	meta := writeSidecar(imageName, reference, currentTime, sidx, fired)
+	record.save()
+
+	// upload to targets in background, sidecar follows image on each target
+	if uploading {
+		image := upload.File{Path: imageName, Time: currentTime, Score: sidx}
+		files := []upload.File{image}
+		if meta != "" {
+			files = append(files, upload.File{Path: meta, Time: currentTime, Score: sidx})
+		}
+		// alert of uploaded frame links the uploaded image when upload finishes in time
+		var sendAlert func(imageURL string)
+		if alert {
+			sendAlert = delayAlert(record, currentTime, sidx)
+		}
+		hold(imageName)
+		upload.Submit(files, record.frame.Alert, func(results []upload.Result) {
+			defer release(imageName)
+			logResults(image, results)
+			if alert {
+				sendAlert(uploadedURL(results))
+			}
+			if len(results) == 0 {
+				return
+			}
+			record.update(func(f *index.Frame) {
+				f.Actions = append(f.Actions, "upload")
+				f.Upload = index.StatusOK
+				if upload.Failed(results) != nil {
+					f.Upload = index.StatusFailed
+				}
+			})
+		})
+	}
+
+	if notifyTamper {
+		log.Printf("Camera tamper suspected (%s, sim=%.2f)\n", imageName, sidx)
+		n := notify.New(notify.KindTamper, currentTime, fmt.Sprintf("Whole scene changed, camera may be covered or moved, diff=%0.2f", sidx))
+		n.Score = sidx
+		n.Image = imageName
+		hold(imageName)
+		routed := notify.Submit(n, func(err error) {
+			release(imageName)
 			if err != nil {
-				log.Printf("Failed to send FTP failure: %s\n", err)
+				log.Printf("Failed to send tamper notification: %s\n", err)
 			}
-		} else {
-			log.Printf("FTP upload success (%s, sim=%.2f)\n", imageName, sidx)
+		})
+		if !routed {
+			release(imageName)
 		}
 	}
 
-	// send email alert
-	if sidx > cfg.Settings.EmailThreshold && currentTime.Sub(lastAlert).Seconds() > float64(cfg.Settings.EmailInterval) {
-		err = email.SendEmail(fmt.Sprintf("CAMERA ALERT: %s", cfg.Settings.Id),
-			fmt.Sprintf("%s diff=%0.2f", time.Now().Format(time.RFC3339), sidx),
-			[]string{imageName})
-		if err != nil {
-			log.Printf("Failed to send email alert (%s, sim=%.2f): %s\n", imageName, sidx, err)
-		} else {
-			log.Printf("Email alert success (%s, sim=%.2f)\n", imageName, sidx)
+	// send alert in background, alert of uploaded frame is sent once upload finished or alertURLWait passed
+	if alert {
+		if !uploading {
+			submitAlert(record, currentTime, sidx, "")
 		}
 		lastAlert = time.Now()
 	}
 }
+
+// captureFailed logs and notifies capture failure once, failure seen while muted is notified when mute ends
+func captureFailed(t time.Time, err error) {
+	first := !captureFailing
+	if first {
+		captureFailing = true
+		log.Printf("Failed to capture image: %s\n", err)
+	}
+	if captureNotified || t.Before(notify.MutedUntil()) {
+		return
+	}
+	if !first {
+		log.Printf("Camera capture still failing after mute: %s\n", err)
+	}
+	captureNotified = true
+	notify.Submit(notify.New(notify.KindCaptureFailure, t, fmt.Sprintf("Failed to capture camera: %s", err)), func(err error) {
+		if err != nil {
+			log.Printf("Failed to send capture failure: %s\n", err)
+		}
+	})
+}
+
+// captureRecovered ends capture failure, recovery is notified only when the failure was
+func captureRecovered(t time.Time) {
+	if !captureFailing {
+		return
+	}
+	captureFailing = false
+	log.Println("Camera capture recovered")
+	if !captureNotified {
+		return
+	}
+	captureNotified = false
+	n := notify.New(notify.KindRecovery, t, "Camera capture recovered")
+	n.Resolves = notify.KindCaptureFailure
+	notify.Submit(n, nil)
+}
+
+// setReference makes image reference of following frames, reference is kept from disk eviction
+func setReference(imageName string) {
+	if lastImage != "" {
+		storage.Unpin(lastImage)
+	}
+	storage.Pin(imageName)
+	lastImage = imageName
+}
+
+// addToIndex records kept frame in event index
+func addToIndex(record index.Frame) {
+	if err := index.AddFrame(record); err != nil {
+		log.Printf("Failed to add frame to index (%s): %s\n", record.Path, err)
+	}
+}
+
+// isIdleSnapshot reports whether nothing was kept for timelapse idle interval
+func isIdleSnapshot(t time.Time) bool {
+	return cfg.Timelapse.Enabled && cfg.Timelapse.IdleInterval > 0 &&
+		t.Sub(lastKept).Seconds() >= float64(cfg.Timelapse.IdleInterval)
+}
@@ -0,0 +1,40 @@
+package process
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/notify"
+)
+
+func TestCaptureFailureAfterMute(t *testing.T) {
+	_, _, kinds := eventSetup(t)
+	captureFailing, captureNotified = false, false
+	now := time.Now()
+	notify.Mute(now.Add(time.Minute))
+	t.Cleanup(func() { notify.Mute(time.Time{}) })
+	failure := errors.New("connection refused")
+
+	// failure seen while muted is notified once when mute ends
+	captureFailed(now, failure)
+	captureFailed(now.Add(30*time.Second), failure)
+	captureFailed(now.Add(2*time.Minute), failure)
+	captureFailed(now.Add(3*time.Minute), failure)
+	captureRecovered(now.Add(4 * time.Minute))
+
+	for _, want := range []notify.Kind{notify.KindCaptureFailure, notify.KindRecovery} {
+		select {
+		case kind := <-kinds:
+			if kind != string(want) {
+				t.Errorf("got %s notification, want %s", kind, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("%s was not notified", want)
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	if len(kinds) != 0 {
+		t.Errorf("unexpected notification %s", <-kinds)
+	}
+}
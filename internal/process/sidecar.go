@@ -0,0 +1,80 @@
+package process
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	img "github.com/kornelkabele/watchdog/internal/image"
+)
+
+// sidecar is JSON metadata stored next to kept image describing why it was kept
+type sidecar struct {
+	Camera          string     `json:"camera"`
+	Image           string     `json:"image"`
+	Time            time.Time  `json:"time"`
+	SimilarityIndex float32    `json:"similarityIndex"`
+	Reference       string     `json:"reference"`
+	Detector        detector   `json:"detector"`
+	Thresholds      thresholds `json:"thresholds"`
+	Fired           []string   `json:"fired"`
+}
+
+type detector struct {
+	Method      string  `json:"method"`
+	Blur        float64 `json:"blur"`
+	Sensitivity float32 `json:"sensitivity"`
+}
+
+type thresholds struct {
+	Keep   float32 `json:"keep"`
+	Upload float32 `json:"upload"`
+	Email  float32 `json:"email"`
+}
+
+// sidecarName derives sidecar file name from image name
+func sidecarName(imageName string) string {
+	return strings.TrimSuffix(imageName, filepath.Ext(imageName)) + ".json"
+}
+
+// writeSidecar stores image metadata and returns sidecar path, empty if sidecars are disabled or writing failed
+func writeSidecar(imageName, reference string, t time.Time, sidx float32, fired []string) string {
+	if !cfg.Settings.Sidecar {
+		return ""
+	}
+	meta := sidecar{
+		Camera:          cfg.Settings.Id,
+		Image:           filepath.Base(imageName),
+		Time:            t,
+		SimilarityIndex: sidx,
+		Reference:       filepath.Base(reference),
+		Detector: detector{
+			Method:      img.Method,
+			Blur:        img.BlurSigma,
+			Sensitivity: cfg.Settings.Sensitivity,
+		},
+		Thresholds: thresholds{
+			Keep:   cfg.Settings.KeepThreshold,
+			Upload: cfg.Settings.UploadThreshold,
+			Email:  cfg.Settings.EmailThreshold,
+		},
+		Fired: fired,
+	}
+	if meta.Fired == nil {
+		meta.Fired = []string{}
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(sidecarName(imageName), data, 0644)
+	}
+	if err != nil {
+		log.Printf("Failed to write sidecar (%s): %s\n", imageName, err)
+		return ""
+	}
+	return sidecarName(imageName)
+}
@@ -0,0 +1,190 @@
+package queue
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	bolt "go.etcd.io/bbolt"
+)
+
+var uploadsBucket = []byte("uploads")
+
+// Item is a failed upload waiting for retry
+type Item struct {
+	ID     uint64            `json:"id"`
+	Target string            `json:"target"`
+	Src    string            `json:"src"`
+	Dst    string            `json:"dst"`
+	Meta   map[string]string `json:"meta,omitempty"`
+	// Captured is time of uploaded content such as capture of frame, it dates manifest entry
+	Captured  time.Time `json:"captured"`
+	Added     time.Time `json:"added"`
+	Attempts  int       `json:"attempts"`
+	LastError string    `json:"lastError,omitempty"`
+}
+
+// key orders items by insertion
+func key(id uint64) []byte {
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint64(b, id)
+	return b
+}
+
+// Queue is opened only for a single transaction, so that CLI can list it alongside capture
+var (
+	mu     sync.Mutex
+	opened bool
+)
+
+// Init creates queue bucket and accepts writes until Close
+func Init() error {
+	mu.Lock()
+	defer mu.Unlock()
+	d, err := bolt.Open(cfg.Settings.QueueFile, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("cannot open queue: %s", err)
+	}
+	defer d.Close()
+	err = d.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(uploadsBucket)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("cannot open queue: %s", err)
+	}
+	opened = true
+	return nil
+}
+
+// Close stops accepting writes
+func Close() error {
+	mu.Lock()
+	opened = false
+	mu.Unlock()
+	return nil
+}
+
+// update opens queue for a single write transaction
+func update(fn func(b *bolt.Bucket) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if !opened {
+		return errors.New("queue is not open")
+	}
+	d, err := bolt.Open(cfg.Settings.QueueFile, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return fmt.Errorf("cannot open queue: %s", err)
+	}
+	defer d.Close()
+	return d.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(uploadsBucket)
+		if err != nil {
+			return err
+		}
+		return fn(b)
+	})
+}
+
+// view opens queue read-only for a single read transaction, missing queue is empty
+func view(fn func(b *bolt.Bucket) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, err := os.Stat(cfg.Settings.QueueFile); os.IsNotExist(err) {
+		return nil
+	}
+	d, err := bolt.Open(cfg.Settings.QueueFile, 0644, &bolt.Options{Timeout: 5 * time.Second, ReadOnly: true})
+	if err == bolt.ErrTimeout {
+		return errors.New("cannot open queue: it is locked by another process")
+	}
+	if err != nil {
+		return fmt.Errorf("cannot open queue: %s", err)
+	}
+	defer d.Close()
+	return d.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(uploadsBucket)
+		if b == nil {
+			return nil
+		}
+		return fn(b)
+	})
+}
+
+// Add appends item to the end of queue
+func Add(item Item) error {
+	return update(func(b *bolt.Bucket) error {
+		id, err := b.NextSequence()
+		if err != nil {
+			return err
+		}
+		item.ID = id
+		if item.Added.IsZero() {
+			item.Added = time.Now()
+		}
+		return put(b, item)
+	})
+}
+
+// Update stores changed attempt count and error of queued item
+func Update(item Item) error {
+	return update(func(b *bolt.Bucket) error {
+		if b.Get(key(item.ID)) == nil {
+			return nil
+		}
+		return put(b, item)
+	})
+}
+
+// Remove deletes item from queue
+func Remove(id uint64) error {
+	return update(func(b *bolt.Bucket) error {
+		return b.Delete(key(id))
+	})
+}
+
+// Items returns queued items oldest first
+func Items() ([]Item, error) {
+	var items []Item
+	err := view(func(b *bolt.Bucket) error {
+		return b.ForEach(func(_, v []byte) error {
+			var item Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	return items, err
+}
+
+// Stats returns number of queued items and time when the oldest one was queued
+func Stats() (count int, oldest time.Time, err error) {
+	err = view(func(b *bolt.Bucket) error {
+		count = b.Stats().KeyN
+		_, v := b.Cursor().First()
+		if v == nil {
+			return nil
+		}
+		var item Item
+		if err := json.Unmarshal(v, &item); err != nil {
+			return err
+		}
+		oldest = item.Added
+		return nil
+	})
+	return
+}
+
+func put(b *bolt.Bucket, item Item) error {
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	return b.Put(key(item.ID), data)
+}
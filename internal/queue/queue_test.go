@@ -0,0 +1,85 @@
+package queue
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	bolt "go.etcd.io/bbolt"
+)
+
+func TestQueue(t *testing.T) {
+	cfg.Settings.QueueFile = filepath.Join(t.TempDir(), "queue.db")
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	defer Close()
+
+	if count, _, err := Stats(); err != nil || count != 0 {
+		t.Fatalf("expected empty queue, got %d, %v", count, err)
+	}
+
+	added := time.Now().Add(-time.Hour)
+	for i, src := range []string{"images/a.jpg", "images/b.jpg", "images/c.jpg"} {
+		item := Item{Target: "nas", Src: src, Dst: src, Attempts: 1}
+		if i == 0 {
+			item.Added = added
+		}
+		if err := Add(item); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// CLI in another process lists queue while capture keeps it open for writes
+	d, err := bolt.Open(cfg.Settings.QueueFile, 0644, &bolt.Options{Timeout: 100 * time.Millisecond, ReadOnly: true})
+	if err != nil {
+		t.Fatalf("queue locked by open writer: %s", err)
+	}
+	d.Close()
+
+	items, err := Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 3 || items[0].Src != "images/a.jpg" || items[2].Src != "images/c.jpg" {
+		t.Fatalf("unexpected items %v", items)
+	}
+
+	items[1].Attempts = 2
+	if err := Update(items[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := Remove(items[0].ID); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err = Items()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 2 || items[0].Src != "images/b.jpg" || items[0].Attempts != 2 {
+		t.Errorf("unexpected items after update %v", items)
+	}
+	count, oldest, err := Stats()
+	if err != nil || count != 2 || !oldest.Equal(items[0].Added) {
+		t.Errorf("unexpected stats %d, %s, %v", count, oldest, err)
+	}
+}
+
+func TestListWithoutInit(t *testing.T) {
+	cfg.Settings.QueueFile = filepath.Join(t.TempDir(), "queue.db")
+	if err := Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := Add(Item{Target: "nas", Src: "images/a.jpg"}); err != nil {
+		t.Fatal(err)
+	}
+	Close()
+
+	// CLI lists queue read-only without holding it open
+	items, err := Items()
+	if err != nil || len(items) != 1 {
+		t.Errorf("expected 1 item from closed queue, got %v, %v", items, err)
+	}
+}
@@ -0,0 +1,156 @@
+package s3
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/file"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// partSize is the smallest part S3 accepts, files above it such as video clips are sent as multipart uploads
+const partSize = 5 * 1024 * 1024
+
+// S3 uploads files to S3-compatible object storage
+type S3 struct {
+	endpoint *url.URL
+	bucket   string
+	client   *minio.Client
+}
+
+// New creates S3 uploader of given target, credentials are taken from target or AWS_* / MINIO_* environment
+func New(target cfg.ConfigTarget) (*S3, error) {
+	return newS3(target, nil)
+}
+
+// newS3 creates S3 uploader using given transport, default transport is used when nil
+func newS3(target cfg.ConfigTarget, transport http.RoundTripper) (*S3, error) {
+	endpoint, err := url.Parse(target.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Host == "" {
+		return nil, fmt.Errorf("endpoint %q must be an URL", target.Endpoint)
+	}
+
+	creds := credentials.NewChainCredentials([]credentials.Provider{
+		&credentials.Static{Value: credentials.Value{
+			AccessKeyID:     target.User,
+			SecretAccessKey: target.Pass,
+			SignerType:      credentials.SignatureV4,
+		}},
+		&credentials.EnvAWS{},
+		&credentials.EnvMinio{},
+	})
+	lookup := minio.BucketLookupAuto
+	if target.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+	client, err := minio.New(endpoint.Host, &minio.Options{
+		Creds:        creds,
+		Secure:       endpoint.Scheme == "https",
+		Region:       target.Region,
+		BucketLookup: lookup,
+		Transport:    transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3{endpoint, target.Bucket, client}, nil
+}
+
+// Upload uploads src file to dst object key
+func (s *S3) Upload(src, dst string) error {
+	return s.UploadMetadata(src, dst, nil)
+}
+
+// UploadMetadata uploads src file to dst object key with user metadata headers
+func (s *S3) UploadMetadata(src, dst string, meta map[string]string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	opts := minio.PutObjectOptions{
+		ContentType:  mime.TypeByExtension(filepath.Ext(src)),
+		UserMetadata: meta,
+		PartSize:     partSize,
+	}
+	if opts.ContentType == "" {
+		opts.ContentType = "application/octet-stream"
+	}
+	_, err := s.client.FPutObject(ctx, s.bucket, s.key(dst), src, opts)
+	return err
+}
+
+// Size returns size of uploaded object
+func (s *S3) Size(dst string) (int64, error) {
+	info, err := s.stat(dst)
+	return info.Size, err
+}
+
+// Checksum returns MD5 of uploaded object, ETag of multipart upload is not a checksum and empty string is returned
+func (s *S3) Checksum(dst string) (string, error) {
+	info, err := s.stat(dst)
+	if err != nil {
+		return "", err
+	}
+	etag := strings.Trim(info.ETag, "\"")
+	if strings.Contains(etag, "-") {
+		return "", nil
+	}
+	return etag, nil
+}
+
+func (s *S3) stat(dst string) (minio.ObjectInfo, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return s.client.StatObject(ctx, s.bucket, s.key(dst), minio.StatObjectOptions{})
+}
+
+// URL returns location of uploaded object
+func (s *S3) URL(dst string) string {
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(s.endpoint.String(), "/"), s.bucket, s.key(dst))
+}
+
+// Walk calls fn for every object below root, S3 has no directories
+func (s *S3) Walk(root string, fn func(path string, info os.FileInfo) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	prefix := strings.Trim(root, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if obj.Err != nil {
+			return obj.Err
+		}
+		if err := fn(obj.Key, file.NewInfo(path.Base(obj.Key), obj.Size, obj.LastModified, false)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes object, directories exist only as key prefixes and need no removal
+func (s *S3) Remove(dst string, dir bool) error {
+	if dir {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	return s.client.RemoveObject(ctx, s.bucket, s.key(dst), minio.RemoveObjectOptions{})
+}
+
+func (s *S3) key(dst string) string {
+	return strings.TrimPrefix(dst, "/")
+}
@@ -0,0 +1,72 @@
+package s3
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+func TestUpload(t *testing.T) {
+	backend := s3mem.New()
+	if err := backend.CreateBucket("watchdog"); err != nil {
+		t.Fatal(err)
+	}
+	// fake server does not decode streaming signatures which are used over plain http
+	server := httptest.NewTLSServer(gofakes3.New(backend).Server())
+	defer server.Close()
+
+	s, err := newS3(cfg.ConfigTarget{
+		Endpoint:  server.URL,
+		Bucket:    "watchdog",
+		Region:    "us-east-1",
+		PathStyle: true,
+		User:      "access",
+		Pass:      "secret",
+	}, server.Client().Transport)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	image := filepath.Join(dir, "0007-0001.jpg")
+	if err := ioutil.WriteFile(image, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err = s.UploadMetadata(image, "garden/2021/03/14/0007-0001.jpg", map[string]string{"Similarity-Index": "0.2300"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := backend.HeadObject("watchdog", "garden/2021/03/14/0007-0001.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Metadata["Content-Type"] != "image/jpeg" {
+		t.Errorf("unexpected content type %q", obj.Metadata["Content-Type"])
+	}
+	if obj.Metadata["X-Amz-Meta-Similarity-Index"] != "0.2300" {
+		t.Errorf("unexpected metadata %v", obj.Metadata)
+	}
+
+	// clip above part size is sent as multipart upload
+	clip := filepath.Join(dir, "0007-0001-clip.mp4")
+	data := bytes.Repeat([]byte("0123456789abcdef"), (partSize+partSize/2)/16)
+	if err := ioutil.WriteFile(clip, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Upload(clip, "/garden/0007-0001-clip.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	obj, err = backend.HeadObject("watchdog", "garden/0007-0001-clip.mp4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj.Size != int64(len(data)) {
+		t.Errorf("expected %d bytes, got %d", len(data), obj.Size)
+	}
+}
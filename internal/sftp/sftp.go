@@ -0,0 +1,274 @@
+package sftp
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// resumeSize is size of files such as clips whose interrupted uploads are resumed
+const resumeSize = 1024 * 1024
+
+// SFTP uploads files over SSH keeping the connection open between uploads
+type SFTP struct {
+	host       string
+	port       int
+	user       string
+	pass       string
+	keyFile    string
+	knownHosts string
+
+	mu     sync.Mutex
+	conn   *ssh.Client
+	client *sftp.Client
+}
+
+// New creates SFTP uploader of given target
+func New(target cfg.ConfigTarget) *SFTP {
+	return &SFTP{
+		host:       target.Host,
+		port:       target.Port,
+		user:       target.User,
+		pass:       target.Pass,
+		keyFile:    target.KeyFile,
+		knownHosts: target.KnownHosts,
+	}
+}
+
+// Upload uploads src file to dst path, a broken connection is redialed once
+func (s *SFTP) Upload(src, dst string) error {
+	return s.retry(src, dst, true)
+}
+
+// UploadWhole uploads src file like Upload but never resumes part left by earlier upload
+func (s *SFTP) UploadWhole(src, dst string) error {
+	return s.retry(src, dst, false)
+}
+
+func (s *SFTP) retry(src, dst string, resume bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := s.upload(src, dst, resume)
+	if err == nil || s.client == nil {
+		return err
+	}
+	// connection may have been dropped by server while idle
+	s.close()
+	return s.upload(src, dst, resume)
+}
+
+// URL returns location of uploaded file
+func (s *SFTP) URL(dst string) string {
+	return fmt.Sprintf("sftp://%s/%s", s.address(), strings.TrimPrefix(dst, "/"))
+}
+
+// Close closes SFTP connection
+func (s *SFTP) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.close()
+}
+
+// Walk calls fn for every file and directory below root
+func (s *SFTP) Walk(root string, fn func(path string, info os.FileInfo) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureConnected(); err != nil {
+		return err
+	}
+
+	root = strings.Trim(root, "/")
+	if root == "" {
+		root = "."
+	}
+	walker := s.client.Walk(root)
+	for walker.Step() {
+		if err := walker.Err(); err != nil {
+			if os.IsNotExist(err) && walker.Path() == root {
+				return nil
+			}
+			s.close()
+			return err
+		}
+		if walker.Path() == root {
+			continue
+		}
+		if err := fn(strings.TrimPrefix(walker.Path(), "./"), walker.Stat()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Remove removes file or empty directory
+func (s *SFTP) Remove(dst string, dir bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureConnected(); err != nil {
+		return err
+	}
+	if dir {
+		return s.client.RemoveDirectory(dst)
+	}
+	return s.client.Remove(dst)
+}
+
+func (s *SFTP) ensureConnected() error {
+	if s.client != nil {
+		return nil
+	}
+	return s.connect()
+}
+
+func (s *SFTP) upload(src, dst string, resume bool) error {
+	if err := s.ensureConnected(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := s.client.MkdirAll(path.Dir(dst)); err != nil {
+		return err
+	}
+
+	// upload under temporary name so that consumers never see partial files
+	part := dst + ".part"
+	out, err := s.openPart(part, f, resume)
+	if err != nil {
+		return err
+	}
+	if _, err := out.ReadFrom(f); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if err := s.client.PosixRename(part, dst); err != nil {
+		s.client.Remove(dst)
+		return s.client.Rename(part, dst)
+	}
+	return nil
+}
+
+// openPart opens temporary remote file, large file continues where earlier interrupted upload stopped when resumed
+func (s *SFTP) openPart(part string, f *os.File, resume bool) (*sftp.File, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if resume && fi.Size() >= resumeSize {
+		if remote, err := s.client.Stat(part); err == nil && remote.Size() < fi.Size() {
+			out, err := s.client.OpenFile(part, os.O_WRONLY)
+			if err != nil {
+				return nil, err
+			}
+			if _, err := out.Seek(remote.Size(), io.SeekStart); err != nil {
+				out.Close()
+				return nil, err
+			}
+			if _, err := f.Seek(remote.Size(), io.SeekStart); err != nil {
+				out.Close()
+				return nil, err
+			}
+			return out, nil
+		}
+	}
+	return s.client.Create(part)
+}
+
+// Size returns size of uploaded file
+func (s *SFTP) Size(dst string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.ensureConnected(); err != nil {
+		return 0, err
+	}
+	fi, err := s.client.Stat(dst)
+	if err != nil {
+		return 0, err
+	}
+	return fi.Size(), nil
+}
+
+func (s *SFTP) connect() error {
+	hostKeyCallback, err := knownhosts.New(s.knownHosts)
+	if err != nil {
+		return fmt.Errorf("cannot load known hosts: %s", err)
+	}
+
+	var auth []ssh.AuthMethod
+	if s.keyFile != "" {
+		signer, err := s.signer()
+		if err != nil {
+			return err
+		}
+		auth = append(auth, ssh.PublicKeys(signer))
+	} else {
+		auth = append(auth, ssh.Password(s.pass))
+	}
+
+	config := &ssh.ClientConfig{
+		User:            s.user,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         10 * time.Second,
+	}
+	conn, err := ssh.Dial("tcp", s.address(), config)
+	if err != nil {
+		return err
+	}
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	s.conn, s.client = conn, client
+	return nil
+}
+
+// signer loads private key, pass is used as passphrase of encrypted keys
+func (s *SFTP) signer() (ssh.Signer, error) {
+	key, err := ioutil.ReadFile(s.keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read private key: %s", err)
+	}
+	if s.pass != "" {
+		return ssh.ParsePrivateKeyWithPassphrase(key, []byte(s.pass))
+	}
+	return ssh.ParsePrivateKey(key)
+}
+
+func (s *SFTP) close() error {
+	if s.client == nil {
+		return nil
+	}
+	s.client.Close()
+	err := s.conn.Close()
+	s.client, s.conn = nil, nil
+	return err
+}
+
+func (s *SFTP) address() string {
+	port := s.port
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(s.host, fmt.Sprint(port))
+}
@@ -0,0 +1,212 @@
+package sftp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// startServer runs in-process SSH server with sftp subsystem accepting given client key
+func startServer(t *testing.T, root string, clientKey ssh.PublicKey) (string, ssh.PublicKey) {
+	_, hostPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	hostSigner, err := ssh.NewSignerFromKey(hostPriv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unknown key for %s", conn.User())
+		},
+	}
+	config.AddHostKey(hostSigner)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serve(conn, config, root)
+		}
+	}()
+	return listener.Addr().String(), hostSigner.PublicKey()
+}
+
+func serve(conn net.Conn, config *ssh.ServerConfig, root string) {
+	_, chans, reqs, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go ssh.DiscardRequests(reqs)
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+				if !ok {
+					continue
+				}
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(root))
+				if err != nil {
+					return
+				}
+				server.Serve()
+				server.Close()
+			}
+		}()
+	}
+}
+
+// connect starts server with key authentication and returns uploader connected to it
+func connect(t *testing.T) (*SFTP, string) {
+	dir := t.TempDir()
+	root := filepath.Join(dir, "remote")
+	if err := os.Mkdir(root, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	clientPub, clientPriv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(clientPriv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyFile := filepath.Join(dir, "id_ed25519")
+	if err := ioutil.WriteFile(keyFile, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatal(err)
+	}
+	clientKey, err := ssh.NewPublicKey(clientPub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr, hostKey := startServer(t, root, clientKey)
+	knownHosts := filepath.Join(dir, "known_hosts")
+	line := knownhosts.Line([]string{knownhosts.Normalize(addr)}, hostKey)
+	if err := ioutil.WriteFile(knownHosts, []byte(line+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	host, port, _ := net.SplitHostPort(addr)
+	var portNumber int
+	fmt.Sscan(port, &portNumber)
+	s := New(cfg.ConfigTarget{Host: host, Port: portNumber, User: "pi", KeyFile: keyFile, KnownHosts: knownHosts})
+	t.Cleanup(func() { s.Close() })
+	return s, root
+}
+
+func TestUpload(t *testing.T) {
+	s, root := connect(t)
+	dir := t.TempDir()
+
+	src := filepath.Join(dir, "0007-0001.jpg")
+	if err := ioutil.WriteFile(src, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dst := range []string{"garden/2021/03/14/0007-0001.jpg", "garden/2021/03/14/0007-0002.jpg"} {
+		if err := s.Upload(src, dst); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(dst)))
+		if err != nil || string(data) != "jpeg" {
+			t.Errorf("unexpected remote file %s: %q, %v", dst, data, err)
+		}
+	}
+
+	var files []string
+	err := s.Walk("garden", func(p string, info os.FileInfo) error {
+		if !info.IsDir() {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil || len(files) != 2 || path.Dir(files[0]) != "garden/2021/03/14" {
+		t.Errorf("unexpected listing %v, %v", files, err)
+	}
+	if err := s.Remove(files[0], false); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestUnknownHostKey(t *testing.T) {
+	dir := t.TempDir()
+	clientPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientKey, _ := ssh.NewPublicKey(clientPub)
+	addr, _ := startServer(t, dir, clientKey)
+
+	knownHosts := filepath.Join(dir, "known_hosts")
+	if err := ioutil.WriteFile(knownHosts, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	var portNumber int
+	fmt.Sscan(port, &portNumber)
+	s := New(cfg.ConfigTarget{Host: host, Port: portNumber, User: "pi", Pass: "secret", KnownHosts: knownHosts})
+
+	if err := s.Upload(knownHosts, "known_hosts"); err == nil {
+		t.Error("expected host key verification failure")
+	}
+}
+
+func TestResume(t *testing.T) {
+	s, root := connect(t)
+
+	data := bytes.Repeat([]byte("0123456789"), resumeSize/5)
+	src := filepath.Join(t.TempDir(), "clip.mp4")
+	if err := ioutil.WriteFile(src, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	// earlier upload was interrupted in the middle
+	if err := ioutil.WriteFile(filepath.Join(root, "clip.mp4.part"), data[:resumeSize], 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Upload(src, "clip.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	remote, err := ioutil.ReadFile(filepath.Join(root, "clip.mp4"))
+	if err != nil || !bytes.Equal(remote, data) {
+		t.Errorf("resumed upload differs, %d bytes, %v", len(remote), err)
+	}
+	if size, err := s.Size("clip.mp4"); err != nil || size != int64(len(data)) {
+		t.Errorf("unexpected size %d, %v", size, err)
+	}
+}
@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/notify"
+)
+
+// alertOnly is set while free space could not be recovered
+var alertOnly int32
+
+// diskFree returns free space of filesystem holding path, tests replace it
+var diskFree = freeSpace
+
+// AlertOnly reports whether only alert images should be stored because disk is low
+func AlertOnly() bool {
+	return atomic.LoadInt32(&alertOnly) == 1
+}
+
+// GuardDisk watches free space of image and log directories periodically, it never returns
+func GuardDisk() {
+	for {
+		checkDisk()
+		time.Sleep(time.Duration(cfg.Disk.Interval) * time.Second)
+	}
+}
+
+// checkDisk evicts oldest routine images when free space drops below low watermark until high watermark is reached,
+// if space cannot be freed it notifies once and switches to alert-only storage
+func checkDisk() {
+	low := uint64(cfg.Disk.LowWatermark) * 1024 * 1024
+	high := uint64(cfg.Disk.HighWatermark) * 1024 * 1024
+
+	free, err := minFreeSpace()
+	if err != nil {
+		log.Printf("Failed to check free disk space: %s\n", err)
+		return
+	}
+	if free >= low {
+		if AlertOnly() && free >= high {
+			atomic.StoreInt32(&alertOnly, 0)
+			log.Printf("Disk space recovered (%d MB free), storing all images\n", free/1024/1024)
+			n := notify.New(notify.KindRecovery, time.Now(), fmt.Sprintf("Disk space recovered, %d MB free", free/1024/1024))
+			n.Resolves = notify.KindDiskLow
+			notify.Submit(n, nil)
+		}
+		return
+	}
+
+	log.Printf("Disk space low (%d MB free), evicting oldest images\n", free/1024/1024)
+	freed := Evict(func() bool {
+		free, err := minFreeSpace()
+		return err == nil && free >= high
+	})
+	if freed {
+		log.Println("Disk space freed")
+		return
+	}
+
+	free, _ = minFreeSpace()
+	if free >= low || AlertOnly() {
+		return
+	}
+	atomic.StoreInt32(&alertOnly, 1)
+	log.Printf("Disk space cannot be freed (%d MB free), storing alert images only\n", free/1024/1024)
+	_, err = notify.Send(notify.New(notify.KindDiskLow, time.Now(), fmt.Sprintf("Only %d MB free, storing alert images only", free/1024/1024)))
+	if err != nil {
+		log.Printf("Failed to send disk low notification: %s\n", err)
+	}
+}
+
+// minFreeSpace returns free space of the fuller of image and log directory filesystems,
+// only images are evicted, logs are bounded by rotation
+func minFreeSpace() (uint64, error) {
+	var min uint64
+	for i, dir := range []string{cfg.Settings.ImageDir, filepath.Dir(cfg.Settings.LogFile)} {
+		free, err := diskFree(dir)
+		if err != nil {
+			return 0, err
+		}
+		if i == 0 || free < min {
+			min = free
+		}
+	}
+	return min, nil
+}
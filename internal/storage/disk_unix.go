@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package storage
+
+import "syscall"
+
+// freeSpace returns bytes available to unprivileged user on filesystem holding path
+func freeSpace(path string) (uint64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	return stat.Bavail * uint64(stat.Bsize), nil
+}
@@ -0,0 +1,19 @@
+//go:build windows
+// +build windows
+
+package storage
+
+import "golang.org/x/sys/windows"
+
+// freeSpace returns bytes available to current user on volume holding path
+func freeSpace(path string) (uint64, error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var free, total, totalFree uint64
+	if err := windows.GetDiskFreeSpaceEx(dir, &free, &total, &totalFree); err != nil {
+		return 0, err
+	}
+	return free, nil
+}
@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/file"
+	"github.com/kornelkabele/watchdog/internal/pathtmpl"
+)
+
+var (
+	lastDir string
+	lastSeq int
+)
+
+// PathData is available to path templates besides strftime verbs
+type PathData struct {
+	Camera string
+	Seq    string
+}
+
+// NextImagePath returns unused local path of image captured at given time and creates its directory,
+// sequence restarts whenever the expanded directory changes and skips names that already exist
+func NextImagePath(t time.Time) (string, error) {
+	var previous string
+	for {
+		rel, err := pathtmpl.Expand(cfg.Storage.PathTemplate, t, PathData{cfg.Settings.Id, fmt.Sprintf("%04d", lastSeq+1)})
+		if err != nil {
+			return "", err
+		}
+		path := filepath.Join(cfg.Settings.ImageDir, filepath.FromSlash(rel))
+		dir := filepath.Dir(path)
+		// directory may have been removed by retention meanwhile
+		if err := file.CreateDir(dir); err != nil {
+			return "", err
+		}
+		if dir != lastDir {
+			if lastDir != "" {
+				lastDir, lastSeq = dir, 0
+				continue
+			}
+			lastDir = dir
+		}
+		lastSeq++
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			return path, nil
+		}
+		if path == previous {
+			return "", fmt.Errorf("path template does not produce unique name: %s", path)
+		}
+		previous = path
+	}
+}
+
+// RemoteDir returns directory of stored file relative to image directory using forward slashes
+func RemoteDir(path string) string {
+	rel, err := filepath.Rel(cfg.Settings.ImageDir, filepath.Dir(path))
+	if err != nil {
+		return ""
+	}
+	return filepath.ToSlash(rel)
+}
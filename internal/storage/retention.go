@@ -0,0 +1,231 @@
+package storage
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/index"
+	"github.com/kornelkabele/watchdog/internal/queue"
+)
+
+// mu serializes retention runs and space eviction
+var mu sync.Mutex
+
+// evictBatch is number of files removed before free space is checked again
+const evictBatch = 20
+
+var (
+	pinMu sync.Mutex
+	// pins counts users of stored images, such as reference and deliveries, that eviction must keep
+	pins = map[string]int{}
+)
+
+// Pin keeps stored image along with its sidecar, clip and montage from eviction until Unpin is called
+func Pin(path string) {
+	pinMu.Lock()
+	pins[path]++
+	pinMu.Unlock()
+}
+
+// Unpin ends one Pin of stored image
+func Unpin(path string) {
+	pinMu.Lock()
+	if pins[path]--; pins[path] <= 0 {
+		delete(pins, path)
+	}
+	pinMu.Unlock()
+}
+
+func pinned(path string) bool {
+	pinMu.Lock()
+	defer pinMu.Unlock()
+	return pins[imageOf(path)] > 0
+}
+
+type storedFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+	alert   bool
+}
+
+// ScheduleRetention applies retention rules periodically, it never returns
+func ScheduleRetention() {
+	for {
+		ApplyRetention()
+		time.Sleep(time.Duration(cfg.Storage.Retention.Interval) * time.Second)
+	}
+}
+
+// ApplyRetention removes stored files by age, then oldest files above total size or file count budget.
+// Alert images are kept for alert max age and are evicted only after all routine files.
+func ApplyRetention() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	r := cfg.Storage.Retention
+	files, err := scan()
+	if err != nil {
+		log.Printf("Failed to scan image directory: %s\n", err)
+		return
+	}
+
+	now := time.Now()
+	var keep, expired []storedFile
+	for _, f := range files {
+		maxAge := r.MaxAge
+		if f.alert {
+			maxAge = r.AlertMaxAge
+		}
+		if maxAge > 0 && now.Sub(f.modTime) > time.Duration(maxAge)*time.Hour {
+			expired = append(expired, f)
+		} else {
+			keep = append(keep, f)
+		}
+	}
+
+	var total int64
+	for _, f := range keep {
+		total += f.size
+	}
+	overBudget := func(count int, size int64) bool {
+		return (r.MaxFiles > 0 && count > r.MaxFiles) || (r.MaxSize > 0 && size > int64(r.MaxSize)*1024*1024)
+	}
+	evicted := evictionOrder(keep)
+	count := len(keep)
+	for _, f := range evicted {
+		if !overBudget(count, total) {
+			break
+		}
+		expired = append(expired, f)
+		count--
+		total -= f.size
+	}
+
+	if removed := remove(expired); len(removed) > 0 {
+		log.Printf("Retention removed %d files\n", len(removed))
+	}
+}
+
+// Evict removes oldest routine files until enough returns true and reports whether it was satisfied,
+// pinned images and files queued for upload are kept
+func Evict(enough func() bool) bool {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if enough() {
+		return true
+	}
+	files, err := scan()
+	if err != nil {
+		log.Printf("Failed to scan image directory: %s\n", err)
+		return false
+	}
+	// files waiting for upload retry are read again once connectivity returns
+	queued := map[string]bool{}
+	items, err := queue.Items()
+	if err != nil {
+		log.Printf("Failed to read upload queue: %s\n", err)
+	}
+	for _, item := range items {
+		queued[item.Src] = true
+	}
+	var batch []storedFile
+	for _, f := range files {
+		if f.alert || queued[f.path] || pinned(f.path) {
+			continue
+		}
+		if batch = append(batch, f); len(batch) < evictBatch {
+			continue
+		}
+		remove(batch)
+		batch = nil
+		if enough() {
+			return true
+		}
+	}
+	remove(batch)
+	return enough()
+}
+
+// evictionOrder returns routine files oldest first followed by alert files oldest first
+func evictionOrder(files []storedFile) []storedFile {
+	ordered := make([]storedFile, len(files))
+	copy(ordered, files)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].alert != ordered[j].alert {
+			return !ordered[i].alert
+		}
+		return ordered[i].modTime.Before(ordered[j].modTime)
+	})
+	return ordered
+}
+
+// scan lists stored files oldest first with alert flag taken from event index
+func scan() ([]storedFile, error) {
+	alerts, err := index.AlertPaths()
+	if err != nil {
+		log.Printf("Failed to read alerts from index: %s\n", err)
+	}
+
+	var files []storedFile
+	err = filepath.Walk(cfg.Settings.ImageDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		files = append(files, storedFile{path, fi.Size(), fi.ModTime(), alerts[imageOf(path)]})
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	return files, err
+}
+
+// imageOf maps sidecars, clips and montages to the image they were derived from
+func imageOf(path string) string {
+	stem := strings.TrimSuffix(path, filepath.Ext(path))
+	stem = strings.TrimSuffix(stem, "-clip")
+	stem = strings.TrimSuffix(stem, "-montage")
+	return stem + ".jpg"
+}
+
+// remove deletes files, their index records and directories left empty
+func remove(files []storedFile) []string {
+	var removed []string
+	dirs := map[string]bool{}
+	for _, f := range files {
+		if err := os.Remove(f.path); err != nil {
+			log.Println(err)
+			continue
+		}
+		removed = append(removed, f.path)
+		dirs[filepath.Dir(f.path)] = true
+	}
+	if err := index.RemoveFrames(removed); err != nil {
+		log.Printf("Failed to remove frames from index: %s\n", err)
+	}
+	for dir := range dirs {
+		removeEmptyDirs(dir)
+	}
+	return removed
+}
+
+// removeEmptyDirs removes directory and its parents up to image directory while they are empty
+func removeEmptyDirs(dir string) {
+	root := filepath.Clean(cfg.Settings.ImageDir)
+	for dir = filepath.Clean(dir); dir != root && strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil || len(entries) > 0 {
+			return
+		}
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
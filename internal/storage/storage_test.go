@@ -0,0 +1,238 @@
+package storage
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/index"
+	"github.com/kornelkabele/watchdog/internal/queue"
+)
+
+func setup(t *testing.T) {
+	dir := t.TempDir()
+	cfg.Settings.Id = "garden"
+	cfg.Settings.ImageDir = filepath.Join(dir, "images")
+	cfg.Settings.IndexFile = filepath.Join(dir, "index.db")
+	if err := index.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { index.Close() })
+	lastDir, lastSeq = "", 0
+}
+
+func TestNextImagePath(t *testing.T) {
+	setup(t)
+	cfg.Storage.PathTemplate = "{{.Camera}}/%Y/%m/%d/%H%M%S-{{.Seq}}.jpg"
+	now := time.Date(2021, 3, 14, 7, 5, 3, 0, time.Local)
+
+	first, err := NextImagePath(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := filepath.Join(cfg.Settings.ImageDir, "garden/2021/03/14/070503-0001.jpg"); first != expected {
+		t.Errorf("expected %s, got %s", expected, first)
+	}
+	if dir := RemoteDir(first); dir != "garden/2021/03/14" {
+		t.Errorf("unexpected remote dir %s", dir)
+	}
+
+	// an existing file must never be overwritten
+	lastSeq = 0
+	if err := ioutil.WriteFile(first, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	second, err := NextImagePath(now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second == first {
+		t.Errorf("path %s reused", second)
+	}
+}
+
+func TestApplyRetention(t *testing.T) {
+	setup(t)
+	cfg.Storage.Retention = cfg.ConfigRetention{MaxAge: 24, AlertMaxAge: 72, MaxFiles: 2}
+
+	now := time.Now()
+	files := []struct {
+		name  string
+		age   time.Duration
+		alert bool
+	}{
+		{"old.jpg", 48 * time.Hour, false},
+		{"old-alert.jpg", 48 * time.Hour, true},
+		{"older-alert.jpg", 96 * time.Hour, true},
+		{"a.jpg", 3 * time.Hour, false},
+		{"b.jpg", 2 * time.Hour, false},
+	}
+	for _, f := range files {
+		path := filepath.Join(cfg.Settings.ImageDir, "00", f.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-f.age), now.Add(-f.age)); err != nil {
+			t.Fatal(err)
+		}
+		if err := index.AddFrame(index.Frame{Path: path, Time: now.Add(-f.age), Alert: f.alert}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	ApplyRetention()
+
+	for name, kept := range map[string]bool{"old.jpg": false, "older-alert.jpg": false, "a.jpg": false, "old-alert.jpg": true, "b.jpg": true} {
+		_, err := os.Stat(filepath.Join(cfg.Settings.ImageDir, "00", name))
+		if kept != (err == nil) {
+			t.Errorf("%s kept=%v, expected %v", name, err == nil, kept)
+		}
+	}
+}
+
+// storeFiles writes routine images one minute apart oldest first followed by an alert image
+func storeFiles(t *testing.T, routine int) (files []string, alert string) {
+	now := time.Now()
+	for i := 0; i <= routine; i++ {
+		path := filepath.Join(cfg.Settings.ImageDir, "00", fmt.Sprintf("%04d.jpg", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		modTime := now.Add(time.Duration(i-routine) * time.Minute)
+		if i == routine {
+			// alert is the oldest file but it is never evicted
+			modTime = now.Add(-time.Hour)
+		}
+		if err := os.Chtimes(path, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+		if err := index.AddFrame(index.Frame{Path: path, Time: modTime, Alert: i == routine}); err != nil {
+			t.Fatal(err)
+		}
+		if i == routine {
+			alert = path
+		} else {
+			files = append(files, path)
+		}
+	}
+	return files, alert
+}
+
+// fakeDisk reports free space in MB returned by free
+func fakeDisk(t *testing.T, free func() uint64) {
+	diskFree = func(string) (uint64, error) { return free() * 1024 * 1024, nil }
+	t.Cleanup(func() { diskFree = freeSpace })
+	atomic.StoreInt32(&alertOnly, 0)
+	t.Cleanup(func() { atomic.StoreInt32(&alertOnly, 0) })
+}
+
+func TestCheckDiskEviction(t *testing.T) {
+	setup(t)
+	cfg.Settings.LogFile = filepath.Join(cfg.Settings.ImageDir, "watchdog.log")
+	cfg.Disk = cfg.ConfigDisk{LowWatermark: 60, HighWatermark: 70}
+	files, alert := storeFiles(t, 45)
+	// every stored file takes 1 MB of 100 MB disk
+	fakeDisk(t, func() uint64 {
+		count := 0
+		filepath.Walk(cfg.Settings.ImageDir, func(_ string, fi os.FileInfo, err error) error {
+			if err == nil && !fi.IsDir() {
+				count++
+			}
+			return nil
+		})
+		return uint64(100 - count)
+	})
+
+	checkDisk()
+
+	// the oldest batch of routine images frees enough space
+	for i, path := range files {
+		_, err := os.Stat(path)
+		if evicted := i < evictBatch; evicted != os.IsNotExist(err) {
+			t.Errorf("%s evicted=%v, expected %v", filepath.Base(path), os.IsNotExist(err), evicted)
+		}
+	}
+	if _, err := os.Stat(alert); err != nil {
+		t.Errorf("alert image was evicted: %v", err)
+	}
+	if AlertOnly() {
+		t.Error("switched to alert-only storage although space was freed")
+	}
+}
+
+func TestCheckDiskAlertOnly(t *testing.T) {
+	setup(t)
+	cfg.Settings.LogFile = filepath.Join(cfg.Settings.ImageDir, "watchdog.log")
+	cfg.Disk = cfg.ConfigDisk{LowWatermark: 10, HighWatermark: 20}
+	files, alert := storeFiles(t, 3)
+	var free uint64 = 5
+	fakeDisk(t, func() uint64 { return free })
+
+	// evicting every routine image does not help
+	checkDisk()
+	if !AlertOnly() {
+		t.Fatal("expected alert-only storage when space cannot be freed")
+	}
+	for _, path := range files {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("routine image %s was not evicted", filepath.Base(path))
+		}
+	}
+	if _, err := os.Stat(alert); err != nil {
+		t.Errorf("alert image was evicted: %v", err)
+	}
+
+	// all images are stored again only once free space reaches high watermark
+	free = 15
+	checkDisk()
+	if !AlertOnly() {
+		t.Error("left alert-only storage below high watermark")
+	}
+	free = 25
+	checkDisk()
+	if AlertOnly() {
+		t.Error("still storing alert images only after space recovered")
+	}
+}
+
+func TestEvictKeepsPinnedAndQueued(t *testing.T) {
+	setup(t)
+	cfg.Settings.QueueFile = filepath.Join(t.TempDir(), "queue.db")
+	if err := queue.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { queue.Close() })
+	files, _ := storeFiles(t, 3)
+	sidecar := strings.TrimSuffix(files[0], ".jpg") + ".json"
+	if err := ioutil.WriteFile(sidecar, []byte("{}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// reference image with its sidecar and image waiting for upload retry are kept
+	Pin(files[0])
+	t.Cleanup(func() { Unpin(files[0]) })
+	if err := queue.Add(queue.Item{Target: "nas", Src: files[1]}); err != nil {
+		t.Fatal(err)
+	}
+	if Evict(func() bool { return false }) {
+		t.Fatal("eviction reported enough space")
+	}
+
+	for path, kept := range map[string]bool{files[0]: true, sidecar: true, files[1]: true, files[2]: false} {
+		if _, err := os.Stat(path); kept != (err == nil) {
+			t.Errorf("%s kept=%v, expected %v", filepath.Base(path), err == nil, kept)
+		}
+	}
+}
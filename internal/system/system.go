@@ -46,7 +46,7 @@ func WaitNetworkAvailable() (ok bool) {
 // SigIntHook attaches function to ^C interrupt signal
 func SigIntHook(f func()) {
 	go func() {
-		sigchan := make(chan os.Signal)
+		sigchan := make(chan os.Signal, 1)
 		signal.Notify(sigchan, os.Interrupt)
 		<-sigchan
 		f()
@@ -106,7 +106,12 @@ func GetCaptureCommand(imageName string) (string, error) {
 		imageName,
 		cfg.Camera,
 	}
-	tmpl, err := template.New("Action").Parse(cfg.Settings.FFmpegCmd)
+	return RenderCommand(cfg.Settings.FFmpegCmd, data)
+}
+
+// RenderCommand processes command template with given data and returns shell command
+func RenderCommand(command string, data interface{}) (string, error) {
+	tmpl, err := template.New("Action").Parse(command)
 	if err != nil {
 		return "", err
 	}
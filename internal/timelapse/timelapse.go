@@ -0,0 +1,167 @@
+package timelapse
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/file"
+	"github.com/kornelkabele/watchdog/internal/upload"
+	"github.com/kornelkabele/watchdog/internal/video"
+)
+
+// Dir is subdirectory of image directory holding generated timelapses
+const Dir = "timelapse"
+
+// pause between downscaled frames so that timelapse does not compete with capture loop for CPU
+const framePause = 50 * time.Millisecond
+
+type storedImage struct {
+	path    string
+	modTime time.Time
+}
+
+// Schedule runs daily timelapse generation of previous day at configured hour, it never returns
+func Schedule() {
+	for {
+		now := time.Now()
+		next := nextRun(now)
+		time.Sleep(next.Sub(now))
+
+		day := next.AddDate(0, 0, -1)
+		if err := Generate(day); err != nil {
+			log.Printf("Failed to generate timelapse (%s): %s\n", day.Format("2006-01-02"), err)
+		}
+	}
+}
+
+// nextRun returns the first configured hour after now
+func nextRun(now time.Time) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), cfg.Timelapse.Hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// Generate builds timelapse of a given day from stored images and uploads it to date folder of all targets
+func Generate(day time.Time) error {
+	date := day.Format("2006-01-02")
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	images, err := findImages(from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	frames := sample(images, time.Duration(cfg.Timelapse.SampleInterval)*time.Second)
+	if len(frames) == 0 {
+		return fmt.Errorf("no images found")
+	}
+	log.Printf("Generating timelapse %s from %d of %d images\n", date, len(frames), len(images))
+
+	outDir := filepath.Join(cfg.Settings.ImageDir, Dir)
+	if err := file.CreateDir(outDir); err != nil {
+		return err
+	}
+
+	var outputs []string
+	if cfg.Timelapse.Format == "video" {
+		clip := filepath.Join(outDir, date+".mp4")
+		enc := video.Encoding{
+			Command:   cfg.Timelapse.VideoCmd,
+			FrameRate: cfg.Timelapse.FrameRate,
+			Preset:    cfg.Timelapse.Preset,
+			Width:     cfg.Timelapse.MaxWidth,
+			Timeout:   time.Hour,
+		}
+		if err := video.FromFrames(frames, clip, enc); err != nil {
+			return err
+		}
+		outputs = append(outputs, clip)
+	} else {
+		seqDir := filepath.Join(outDir, date)
+		if outputs, err = downscale(frames, seqDir); err != nil {
+			return err
+		}
+		defer file.RemoveContents(seqDir)
+	}
+
+	for _, output := range outputs {
+		results := upload.UploadTo(output, date+"/"+filepath.Base(output), from)
+		if failed := upload.Failed(results); failed != nil {
+			return fmt.Errorf("upload of %s to %s failed: %s", output, failed.Target, failed.Err)
+		}
+	}
+	log.Printf("Timelapse %s uploaded (%d files)\n", date, len(outputs))
+	return nil
+}
+
+// findImages returns stored camera images modified in given interval ordered by time
+func findImages(from, to time.Time) ([]storedImage, error) {
+	var images []storedImage
+	err := filepath.Walk(cfg.Settings.ImageDir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.IsDir() {
+			if fi.Name() == Dir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".jpg" || strings.HasSuffix(path, "-montage.jpg") {
+			return nil
+		}
+		if t := fi.ModTime(); !t.Before(from) && t.Before(to) {
+			images = append(images, storedImage{path, t})
+		}
+		return nil
+	})
+	sort.Slice(images, func(i, j int) bool { return images[i].modTime.Before(images[j].modTime) })
+	return images, err
+}
+
+// sample picks images at least interval apart
+func sample(images []storedImage, interval time.Duration) []string {
+	var frames []string
+	var last time.Time
+	for _, image := range images {
+		if len(frames) > 0 && image.modTime.Sub(last) < interval {
+			continue
+		}
+		frames = append(frames, image.path)
+		last = image.modTime
+	}
+	return frames
+}
+
+// downscale writes numbered downscaled copies of frames to directory
+func downscale(frames []string, dir string) ([]string, error) {
+	if err := file.CreateDir(dir); err != nil {
+		return nil, err
+	}
+	var outputs []string
+	for _, frame := range frames {
+		img, err := imaging.Open(frame)
+		if err != nil {
+			log.Printf("Skipping timelapse frame %s: %s\n", frame, err)
+			continue
+		}
+		// skipped frames leave no gaps so that image sequence tools read whole sequence
+		out := filepath.Join(dir, fmt.Sprintf("%06d.jpg", len(outputs)+1))
+		if err := imaging.Save(imaging.Resize(img, cfg.Timelapse.MaxWidth, 0, imaging.Box), out); err != nil {
+			return nil, err
+		}
+		outputs = append(outputs, out)
+		time.Sleep(framePause)
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("no frame could be read")
+	}
+	return outputs, nil
+}
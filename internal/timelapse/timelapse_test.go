@@ -0,0 +1,100 @@
+package timelapse
+
+import (
+	"image/color"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/disintegration/imaging"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/local"
+	"github.com/kornelkabele/watchdog/internal/upload"
+)
+
+func TestNextRun(t *testing.T) {
+	cfg.Timelapse.Hour = 3
+	tests := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2021, 3, 14, 1, 0, 0, 0, time.Local), time.Date(2021, 3, 14, 3, 0, 0, 0, time.Local)},
+		{time.Date(2021, 3, 14, 3, 0, 0, 0, time.Local), time.Date(2021, 3, 15, 3, 0, 0, 0, time.Local)},
+		{time.Date(2021, 3, 14, 23, 0, 0, 0, time.Local), time.Date(2021, 3, 15, 3, 0, 0, 0, time.Local)},
+		{time.Date(2021, 12, 31, 4, 0, 0, 0, time.Local), time.Date(2022, 1, 1, 3, 0, 0, 0, time.Local)},
+	}
+	for _, tt := range tests {
+		if got := nextRun(tt.now); !got.Equal(tt.want) {
+			t.Errorf("next run after %s = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	dir := t.TempDir()
+	cfg.Settings.ImageDir = filepath.Join(dir, "images")
+	cfg.Timelapse = cfg.ConfigTimelapse{Format: "sequence", SampleInterval: 60, MaxWidth: 20}
+	remote := filepath.Join(dir, "remote")
+	upload.Targets = []*upload.Target{{Name: "nas", Uploader: local.New(cfg.ConfigTarget{Dir: remote})}}
+	defer func() { upload.Targets = nil }()
+
+	day := time.Date(2021, 3, 14, 12, 0, 0, 0, time.Local)
+	midnight := time.Date(2021, 3, 14, 0, 0, 0, 0, time.Local)
+	images := []struct {
+		name    string
+		modTime time.Time
+		broken  bool
+	}{
+		{"0013-0001.jpg", midnight.Add(-time.Second), false},
+		{"0014-0001.jpg", midnight.Add(10 * time.Second), false},
+		// closer than sample interval to the previous frame
+		{"0014-0002.jpg", midnight.Add(30 * time.Second), false},
+		// unreadable frame leaves no gap in numbering
+		{"0014-0003.jpg", midnight.Add(2 * time.Minute), true},
+		{"0014-0004.jpg", midnight.Add(5 * time.Minute), false},
+		{"0014-0004-montage.jpg", midnight.Add(5 * time.Minute), false},
+		{"0015-0001.jpg", midnight.AddDate(0, 0, 1), false},
+		{"0016-0001.jpg", midnight.AddDate(0, 0, 2), true},
+		{filepath.Join(Dir, "old.jpg"), midnight.Add(time.Hour), false},
+	}
+	for _, image := range images {
+		path := filepath.Join(cfg.Settings.ImageDir, image.name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if image.broken {
+			if err := ioutil.WriteFile(path, []byte("jpeg"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		} else if err := imaging.Save(imaging.New(80, 60, color.White), path); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, image.modTime, image.modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Generate(day); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"000001.jpg", "000002.jpg"} {
+		frame, err := imaging.Open(filepath.Join(remote, "2021-03-14", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame.Bounds().Dx() != 20 {
+			t.Errorf("frame %s not downscaled: %v", name, frame.Bounds())
+		}
+	}
+	if _, err := os.Stat(filepath.Join(remote, "2021-03-14", "000003.jpg")); !os.IsNotExist(err) {
+		t.Errorf("unexpected third frame: %v", err)
+	}
+
+	if err := Generate(day.AddDate(0, 0, 2)); err == nil {
+		t.Error("timelapse of day without readable images should fail")
+	}
+	if err := Generate(day.AddDate(0, 0, 3)); err == nil {
+		t.Error("timelapse of day without images should fail")
+	}
+}
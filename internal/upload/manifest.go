@@ -0,0 +1,117 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/file"
+	"github.com/kornelkabele/watchdog/internal/pathtmpl"
+)
+
+const (
+	// manifestInterval is how often changed manifests are uploaded
+	manifestInterval = 5 * time.Minute
+	// manifestMaxAge is age of local manifest copies that are removed
+	manifestMaxAge = 7 * 24 * time.Hour
+)
+
+// pendingManifest is local manifest waiting for upload to target
+type pendingManifest struct {
+	target *Target
+	remote string
+}
+
+var (
+	manifestMu sync.Mutex
+	// changed manifests by local path
+	changed = map[string]pendingManifest{}
+)
+
+// addToManifest appends checksum of uploaded file to per-day manifest of target in sha256sum format
+func (t *Target) addToManifest(src, dst string, when time.Time) error {
+	if t.Manifest == "" {
+		return nil
+	}
+	remote, err := pathtmpl.Expand(t.Manifest, when, pathData{Camera: cfg.Settings.Id})
+	if err != nil {
+		return err
+	}
+	remote = strings.TrimPrefix(path.Clean("/"+remote), "/")
+	sum, err := file.Checksum(src, sha256.New())
+	if err != nil {
+		return err
+	}
+	// entries are relative to manifest so that sha256sum -c can run in its directory
+	entry := dst
+	if dir := path.Dir(remote); dir != "." && strings.HasPrefix(dst, dir+"/") {
+		entry = strings.TrimPrefix(dst, dir+"/")
+	}
+
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	local := filepath.Join(cfg.Settings.ManifestDir, t.Name, filepath.FromSlash(remote))
+	if err := file.CreateDir(filepath.Dir(local)); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(local, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(f, "%s  %s\n", sum, entry); err != nil {
+		f.Close()
+		return err
+	}
+	changed[local] = pendingManifest{t, remote}
+	return f.Close()
+}
+
+// ScheduleManifests uploads changed manifests periodically, it never returns
+func ScheduleManifests() {
+	for {
+		time.Sleep(manifestInterval)
+		UploadManifests()
+		removeOldManifests()
+	}
+}
+
+// UploadManifests uploads manifests changed since last upload, failed ones are tried next time
+func UploadManifests() {
+	manifestMu.Lock()
+	pending := changed
+	changed = map[string]pendingManifest{}
+	manifestMu.Unlock()
+
+	for local, m := range pending {
+		if err := m.target.transfer(local, m.remote, nil, true); err != nil {
+			log.Printf("Failed to upload manifest to %s (%s): %s\n", m.target.Name, m.remote, err)
+			manifestMu.Lock()
+			changed[local] = m
+			manifestMu.Unlock()
+		}
+	}
+}
+
+// removeOldManifests removes local copies of manifests no longer appended to
+func removeOldManifests() {
+	manifestMu.Lock()
+	defer manifestMu.Unlock()
+	filepath.Walk(cfg.Settings.ManifestDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return nil
+		}
+		if _, ok := changed[p]; !ok && time.Since(info.ModTime()) > manifestMaxAge {
+			if err := os.Remove(p); err != nil {
+				log.Printf("Failed to remove manifest: %s\n", err)
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,116 @@
+package upload
+
+import (
+	"log"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Pruner lists and removes files stored on target
+type Pruner interface {
+	Walk(root string, fn func(path string, info os.FileInfo) error) error
+	Remove(path string, dir bool) error
+}
+
+type remoteFile struct {
+	path    string
+	size    int64
+	modTime time.Time
+}
+
+// SchedulePrune applies remote retention of target periodically, it never returns
+func SchedulePrune(t *Target) {
+	for {
+		Prune(t)
+		time.Sleep(time.Duration(t.Prune.Interval) * time.Second)
+	}
+}
+
+// Prune removes files older than max age, then oldest files above size budget from target.
+// Directories left empty are removed as well. Dry run only logs what would be removed.
+func Prune(t *Target) {
+	p, ok := t.Uploader.(Pruner)
+	if !ok {
+		log.Printf("Target %s does not support pruning\n", t.Name)
+		return
+	}
+
+	r := t.Prune
+	var files []remoteFile
+	var dirs []string
+	// children counts entries of each directory so that emptied directories are found
+	children := map[string]int{}
+	err := p.Walk(r.Root, func(p string, info os.FileInfo) error {
+		children[parent(p)]++
+		if info.IsDir() {
+			dirs = append(dirs, p)
+		} else {
+			files = append(files, remoteFile{p, info.Size(), info.ModTime()})
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to list target %s: %s\n", t.Name, err)
+		return
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+	var total int64
+	for _, f := range files {
+		total += f.size
+	}
+	now := time.Now()
+	var removed, freed int64
+	for _, f := range files {
+		expired := r.MaxAge > 0 && now.Sub(f.modTime) > time.Duration(r.MaxAge)*time.Hour
+		overBudget := r.MaxSize > 0 && total > int64(r.MaxSize)*1024*1024
+		if !expired && !overBudget {
+			break
+		}
+		if !prune(t.Name, p, f.path, false, r.DryRun) {
+			continue
+		}
+		children[parent(f.path)]--
+		total -= f.size
+		removed++
+		freed += f.size
+	}
+
+	// deepest directories first so that parents become empty
+	sort.Slice(dirs, func(i, j int) bool { return strings.Count(dirs[i], "/") > strings.Count(dirs[j], "/") })
+	for _, d := range dirs {
+		if children[d] == 0 && prune(t.Name, p, d, true, r.DryRun) {
+			children[parent(d)]--
+		}
+	}
+
+	if removed > 0 && r.DryRun {
+		log.Printf("Would prune %d files (%d MB) from target %s\n", removed, freed/1024/1024, t.Name)
+	} else if removed > 0 {
+		log.Printf("Pruned %d files (%d MB) from target %s\n", removed, freed/1024/1024, t.Name)
+	}
+}
+
+func prune(target string, p Pruner, name string, dir bool, dryRun bool) bool {
+	if dryRun {
+		log.Printf("Would prune %s from target %s\n", name, target)
+		return true
+	}
+	if err := p.Remove(name, dir); err != nil {
+		log.Printf("Failed to prune %s from target %s: %s\n", name, target, err)
+		return false
+	}
+	log.Printf("Pruned %s from target %s\n", name, target)
+	return true
+}
+
+func parent(p string) string {
+	dir := path.Dir(p)
+	if dir == "." {
+		return ""
+	}
+	return dir
+}
@@ -0,0 +1,65 @@
+package upload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/local"
+)
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	files := map[string]time.Time{
+		"garden/2021-03-14/0007-0001.jpg": now.Add(-72 * time.Hour),
+		"garden/2021-03-14/0007-0002.jpg": now.Add(-71 * time.Hour),
+		"garden/2021-03-16/0007-0001.jpg": now.Add(-2 * time.Hour),
+		"garden/2021-03-16/0008-0001.jpg": now.Add(-1 * time.Hour),
+		"garden/2021-03-16/0009-0001.jpg": now,
+	}
+	for name, modTime := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(p, make([]byte, 1024*1024), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(p, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+		return err == nil
+	}
+
+	target := &Target{
+		Name:     "backup",
+		Prune:    cfg.ConfigPrune{Root: "garden", MaxAge: 48, MaxSize: 2, DryRun: true},
+		Uploader: local.New(cfg.ConfigTarget{Dir: dir}),
+	}
+	Prune(target)
+	for name := range files {
+		if !exists(name) {
+			t.Errorf("dry run removed %s", name)
+		}
+	}
+
+	target.Prune.DryRun = false
+	Prune(target)
+	for _, name := range []string{"garden/2021-03-14", "garden/2021-03-16/0007-0001.jpg"} {
+		if exists(name) {
+			t.Errorf("%s was not pruned", name)
+		}
+	}
+	for _, name := range []string{"garden/2021-03-16/0008-0001.jpg", "garden/2021-03-16/0009-0001.jpg"} {
+		if !exists(name) {
+			t.Errorf("%s was pruned", name)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package upload
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/queue"
+)
+
+const (
+	// retryInterval is how often the upload queue is checked
+	retryInterval = 10 * time.Second
+	// minBackoff and maxBackoff bound the wait after a failed retry of a target
+	minBackoff = 30 * time.Second
+	maxBackoff = 30 * time.Minute
+)
+
+// backoff holds wait of each target after failed retry
+type backoff struct {
+	wait  map[string]time.Duration
+	until map[string]time.Time
+}
+
+func newBackoff() *backoff {
+	return &backoff{map[string]time.Duration{}, map[string]time.Time{}}
+}
+
+// failed doubles wait of target up to maxBackoff
+func (b *backoff) failed(target string, now time.Time) {
+	wait := b.wait[target] * 2
+	if wait < minBackoff {
+		wait = minBackoff
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	b.wait[target] = wait
+	b.until[target] = now.Add(wait)
+}
+
+func (b *backoff) succeeded(target string) {
+	delete(b.wait, target)
+	delete(b.until, target)
+}
+
+func (b *backoff) waiting(target string, now time.Time) bool {
+	return now.Before(b.until[target])
+}
+
+// DrainQueue retries queued uploads periodically, it never returns
+func DrainQueue() {
+	b := newBackoff()
+	for {
+		drain(b, time.Now())
+		time.Sleep(retryInterval)
+	}
+}
+
+// drain retries queued uploads oldest first. After a failure the remaining items of the target
+// wait for exponential backoff so that uploads of each target stay in order.
+func drain(b *backoff, now time.Time) {
+	items, err := queue.Items()
+	if err != nil {
+		log.Printf("Failed to read upload queue: %s\n", err)
+		return
+	}
+	if len(items) == 0 {
+		return
+	}
+
+	targets := map[string]*Target{}
+	for _, t := range Targets {
+		targets[t.Name] = t
+	}
+	blocked := map[string]bool{}
+	retried := 0
+	for _, item := range items {
+		t := targets[item.Target]
+		if t == nil {
+			log.Printf("Dropping queued upload to removed target %s (%s)\n", item.Target, item.Src)
+			removeItem(item)
+			continue
+		}
+		if blocked[t.Name] || b.waiting(t.Name, now) {
+			continue
+		}
+		if _, err := os.Stat(item.Src); os.IsNotExist(err) {
+			log.Printf("Dropping queued upload to %s, file was removed (%s)\n", t.Name, item.Src)
+			removeItem(item)
+			continue
+		}
+
+		retried++
+		captured := item.Captured
+		if captured.IsZero() {
+			// items queued by earlier versions
+			captured = item.Added
+		}
+		_, err := t.put(item.Src, item.Dst, item.Meta, captured)
+		if err != nil {
+			log.Printf("Failed to retry upload to %s (%s, attempt %d): %s\n", t.Name, item.Src, item.Attempts+1, err)
+			item.Attempts++
+			item.LastError = err.Error()
+			if err := queue.Update(item); err != nil {
+				log.Printf("Failed to update upload queue: %s\n", err)
+			}
+			b.failed(t.Name, now)
+			blocked[t.Name] = true
+			continue
+		}
+		log.Printf("Queued upload to %s success (%s)\n", t.Name, item.Src)
+		b.succeeded(t.Name)
+		removeItem(item)
+	}
+
+	if retried > 0 {
+		logStats()
+	}
+}
+
+// logStats logs length of upload queue and age of its oldest item
+func logStats() {
+	count, oldest, err := queue.Stats()
+	if err != nil {
+		log.Printf("Failed to read upload queue: %s\n", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("Upload queue: %d files, oldest queued %s ago\n", count, time.Since(oldest).Round(time.Second))
+	} else {
+		log.Printf("Upload queue is empty\n")
+	}
+}
+
+func removeItem(item queue.Item) {
+	if err := queue.Remove(item.ID); err != nil {
+		log.Printf("Failed to remove item from upload queue: %s\n", err)
+	}
+}
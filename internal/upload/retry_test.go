@@ -0,0 +1,95 @@
+package upload
+
+import (
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/queue"
+)
+
+// flaky fails uploads while down
+type flaky struct {
+	down     bool
+	uploaded []string
+}
+
+func (f *flaky) Upload(src, dst string) error {
+	if f.down {
+		return errors.New("connection refused")
+	}
+	f.uploaded = append(f.uploaded, dst)
+	return nil
+}
+
+func (f *flaky) URL(dst string) string {
+	return dst
+}
+
+// openQueue opens upload queue in directory for duration of test
+func openQueue(t *testing.T, dir string) {
+	cfg.Settings.QueueFile = filepath.Join(dir, "queue.db")
+	if err := queue.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { queue.Close() })
+}
+
+func TestDrain(t *testing.T) {
+	dir := t.TempDir()
+	openQueue(t, dir)
+	nas := &flaky{down: true}
+	Targets = []*Target{{Name: "nas", Uploader: nas}}
+
+	var srcs []string
+	for _, name := range []string{"a.jpg", "b.jpg", "c.jpg"} {
+		src := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(src, []byte("jpeg"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		srcs = append(srcs, src)
+		r := Targets[0].send(src, name, nil, time.Now())
+		if r.Err == nil || !r.Queued {
+			t.Fatalf("expected queued failure, got %v", r)
+		}
+	}
+
+	now := time.Now()
+	b := newBackoff()
+	drain(b, now)
+	items, _ := queue.Items()
+	if len(items) != 3 || items[0].Attempts != 2 || items[1].Attempts != 1 {
+		t.Fatalf("expected single attempt of oldest item, got %v", items)
+	}
+
+	// connectivity returns but target still waits for backoff
+	nas.down = false
+	drain(b, now.Add(minBackoff/2))
+	if len(nas.uploaded) != 0 {
+		t.Fatalf("uploaded during backoff: %v", nas.uploaded)
+	}
+
+	drain(b, now.Add(minBackoff))
+	items, _ = queue.Items()
+	if len(items) != 0 || len(nas.uploaded) != 3 || nas.uploaded[0] != "a.jpg" || nas.uploaded[2] != "c.jpg" {
+		t.Errorf("expected queue drained in order, got %v, %v", items, nas.uploaded)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	b := newBackoff()
+	now := time.Now()
+	for i := 0; i < 10; i++ {
+		b.failed("nas", now)
+	}
+	if b.wait["nas"] != maxBackoff {
+		t.Errorf("expected backoff capped at %s, got %s", maxBackoff, b.wait["nas"])
+	}
+	b.succeeded("nas")
+	if b.waiting("nas", now) {
+		t.Error("expected no wait after success")
+	}
+}
@@ -0,0 +1,243 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/ftp"
+	"github.com/kornelkabele/watchdog/internal/local"
+	"github.com/kornelkabele/watchdog/internal/pathtmpl"
+	"github.com/kornelkabele/watchdog/internal/queue"
+	"github.com/kornelkabele/watchdog/internal/s3"
+	"github.com/kornelkabele/watchdog/internal/sftp"
+	"github.com/kornelkabele/watchdog/internal/storage"
+	"github.com/kornelkabele/watchdog/internal/webdav"
+	"github.com/kornelkabele/watchdog/internal/worker"
+)
+
+// Uploader stores local file at remote path, URL is empty when uploaded file cannot be linked
+type Uploader interface {
+	Upload(src, dst string) error
+	URL(dst string) string
+}
+
+// MetadataUploader stores metadata such as similarity index along with uploaded file
+type MetadataUploader interface {
+	UploadMetadata(src, dst string, meta map[string]string) error
+}
+
+// Target is a configured upload destination
+type Target struct {
+	Name         string
+	PathTemplate string
+	Threshold    float32
+	Prune        cfg.ConfigPrune
+	Manifest     string
+	Uploader
+	jobs *worker.Queue
+}
+
+// File is a stored file to be uploaded
+type File struct {
+	Path  string
+	Time  time.Time
+	Score float32
+}
+
+// Result of upload of a file to a single target
+type Result struct {
+	Target string
+	Path   string
+	URL    string
+	Err    error
+	Queued bool
+}
+
+// pathData is available to target path templates besides strftime verbs
+type pathData struct {
+	Camera string
+	Dir    string
+	Name   string
+}
+
+// Targets are configured upload destinations
+var Targets []*Target
+
+// ErrDropped is result of upload dropped because target fell behind
+var ErrDropped = errors.New("upload dropped, target queue is full")
+
+// Init creates upload targets from configuration
+func Init() {
+	Targets = nil
+	for _, t := range cfg.Targets {
+		u, err := newUploader(t)
+		if err != nil {
+			log.Fatalf("Cannot create upload target %s: %s\n", t.Name, err)
+		}
+		target := &Target{t.Name, t.PathTemplate, t.Threshold, t.Prune, t.Manifest, u, worker.NewQueue(t.Name, cfg.Delivery.QueueSize)}
+		workers := t.Workers
+		if workers == 0 {
+			workers = cfg.Delivery.Workers
+		}
+		target.jobs.Start(workers)
+		Targets = append(Targets, target)
+	}
+}
+
+// Close closes connections of targets which keep them open between uploads
+func Close() {
+	for _, t := range Targets {
+		if c, ok := t.Uploader.(io.Closer); ok {
+			if err := c.Close(); err != nil {
+				log.Printf("Failed to close upload target %s: %s\n", t.Name, err)
+			}
+		}
+	}
+}
+
+func newUploader(t cfg.ConfigTarget) (Uploader, error) {
+	switch t.Type {
+	case "ftp":
+		return ftp.New(t), nil
+	case "sftp":
+		return sftp.New(t), nil
+	case "s3":
+		return s3.New(t)
+	case "webdav":
+		return webdav.New(t), nil
+	case "local":
+		return local.New(t), nil
+	}
+	return nil, fmt.Errorf("unknown target type %s", t.Type)
+}
+
+// Upload sends file to every target whose threshold is below file score
+func Upload(f File) []Result {
+	var results []Result
+	for _, t := range Targets {
+		if f.Score <= t.Threshold {
+			continue
+		}
+		results = append(results, t.uploadFile(f))
+	}
+	return results
+}
+
+// Submit queues upload of files to every target whose threshold is below score of the first file
+// and returns immediately. Following files such as sidecar metadata are uploaded only when the first
+// one succeeds. Routine uploads may be dropped when target falls behind, urgent ones never are.
+// done is called with results of the first file once every target finished or dropped it.
+func Submit(files []File, urgent bool, done func([]Result)) {
+	var selected []*Target
+	for _, t := range Targets {
+		if files[0].Score > t.Threshold {
+			selected = append(selected, t)
+		}
+	}
+	if len(selected) == 0 {
+		if done != nil {
+			done(nil)
+		}
+		return
+	}
+
+	var mu sync.Mutex
+	var results []Result
+	finish := func(r Result) {
+		mu.Lock()
+		results = append(results, r)
+		last := len(results) == len(selected)
+		mu.Unlock()
+		if last && done != nil {
+			done(results)
+		}
+	}
+	for _, t := range selected {
+		t := t
+		t.jobs.Push(worker.Job{
+			Urgent: urgent,
+			Run: func() {
+				r := t.uploadFile(files[0])
+				if r.Err == nil {
+					for _, f := range files[1:] {
+						if rf := t.uploadFile(f); rf.Err != nil {
+							log.Printf("Failed to upload to %s (%s): %s\n", t.Name, f.Path, rf.Err)
+						}
+					}
+				}
+				finish(r)
+			},
+			Drop: func() { finish(Result{Target: t.Name, Err: ErrDropped}) },
+		})
+	}
+}
+
+// UploadTo sends file to fixed remote path of every target regardless of thresholds, when is time of its content
+func UploadTo(src, dst string, when time.Time) []Result {
+	var results []Result
+	for _, t := range Targets {
+		results = append(results, t.send(src, dst, nil, when))
+	}
+	return results
+}
+
+func (t *Target) uploadFile(f File) Result {
+	dst, err := t.RemotePath(f)
+	if err != nil {
+		return Result{Target: t.Name, Err: err}
+	}
+	return t.send(f.Path, dst, f.metadata(), f.Time)
+}
+
+// RemotePath expands target path template for a stored file
+func (t *Target) RemotePath(f File) (string, error) {
+	data := pathData{cfg.Settings.Id, storage.RemoteDir(f.Path), filepath.Base(f.Path)}
+	dst, err := pathtmpl.Expand(t.PathTemplate, f.Time, data)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimPrefix(path.Clean("/"+dst), "/"), nil
+}
+
+// send uploads file captured at given time to target, failed upload is queued for retry
+func (t *Target) send(src, dst string, meta map[string]string, captured time.Time) Result {
+	r := Result{Target: t.Name, Path: dst}
+	r.Path, r.Err = t.put(src, dst, meta, captured)
+	if r.Err == nil {
+		r.URL = t.URL(r.Path)
+		return r
+	}
+	item := queue.Item{Target: t.Name, Src: src, Dst: dst, Meta: meta, Captured: captured, Attempts: 1, LastError: r.Err.Error()}
+	if err := queue.Add(item); err != nil {
+		log.Printf("Failed to queue upload to %s (%s): %s\n", t.Name, src, err)
+	} else {
+		r.Queued = true
+	}
+	return r
+}
+
+func (f File) metadata() map[string]string {
+	return map[string]string{
+		"Camera":           cfg.Settings.Id,
+		"Captured":         f.Time.Format(time.RFC3339),
+		"Similarity-Index": fmt.Sprintf("%.4f", f.Score),
+	}
+}
+
+// Failed returns first failed result
+func Failed(results []Result) *Result {
+	for i := range results {
+		if results[i].Err != nil {
+			return &results[i]
+		}
+	}
+	return nil
+}
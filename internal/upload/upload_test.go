@@ -0,0 +1,84 @@
+package upload
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+)
+
+func TestUpload(t *testing.T) {
+	dir := t.TempDir()
+	cfg.Settings.Id = "garden"
+	cfg.Settings.ImageDir = filepath.Join(dir, "images")
+	openQueue(t, dir)
+	cfg.Targets = []cfg.ConfigTarget{
+		{Name: "all", Type: "local", Threshold: 0.1, PathTemplate: "{{.Dir}}/{{.Name}}", Dir: filepath.Join(dir, "all")},
+		{Name: "alerts", Type: "local", Threshold: 0.3, PathTemplate: "{{.Camera}}/%Y-%m-%d/{{.Name}}", Dir: filepath.Join(dir, "alerts")},
+	}
+	Init()
+
+	src := filepath.Join(cfg.Settings.ImageDir, "00", "0007-0001.jpg")
+	if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(src, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	when := time.Date(2021, 3, 14, 7, 0, 0, 0, time.Local)
+
+	results := Upload(File{Path: src, Time: when, Score: 0.2})
+	if len(results) != 1 || results[0].Target != "all" || results[0].Err != nil {
+		t.Fatalf("unexpected results %v", results)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "all", "00", "0007-0001.jpg")); err != nil {
+		t.Error(err)
+	}
+
+	results = Upload(File{Path: src, Time: when, Score: 0.5})
+	if len(results) != 2 || Failed(results) != nil {
+		t.Fatalf("unexpected results %v", results)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "alerts", "garden", "2021-03-14", "0007-0001.jpg")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSubmit(t *testing.T) {
+	dir := t.TempDir()
+	cfg.Settings.ImageDir = filepath.Join(dir, "images")
+	openQueue(t, dir)
+	cfg.Delivery = cfg.ConfigDelivery{QueueSize: 5, Workers: 1}
+	cfg.Targets = []cfg.ConfigTarget{
+		{Name: "all", Type: "local", Threshold: 0.1, PathTemplate: "{{.Dir}}/{{.Name}}", Dir: filepath.Join(dir, "all")},
+		{Name: "alerts", Type: "local", Threshold: 0.3, PathTemplate: "{{.Dir}}/{{.Name}}", Dir: filepath.Join(dir, "alerts")},
+	}
+	Init()
+
+	var files []File
+	for _, name := range []string{"0007-0001.jpg", "0007-0001.json"} {
+		src := filepath.Join(cfg.Settings.ImageDir, "00", name)
+		if err := os.MkdirAll(filepath.Dir(src), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(src, []byte(name), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, File{Path: src, Time: time.Now(), Score: 0.2})
+	}
+
+	done := make(chan []Result)
+	Submit(files, false, func(results []Result) { done <- results })
+	results := <-done
+	if len(results) != 1 || results[0].Target != "all" || results[0].Err != nil {
+		t.Fatalf("unexpected results %v", results)
+	}
+	for _, name := range []string{"0007-0001.jpg", "0007-0001.json"} {
+		if _, err := os.Stat(filepath.Join(dir, "all", "00", name)); err != nil {
+			t.Error(err)
+		}
+	}
+}
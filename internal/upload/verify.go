@@ -0,0 +1,116 @@
+package upload
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/crypt"
+	"github.com/kornelkabele/watchdog/internal/file"
+)
+
+// Verifier reports size of uploaded file so that truncated uploads are detected
+type Verifier interface {
+	Size(dst string) (int64, error)
+}
+
+// Checksummer reports MD5 of uploaded file, empty string is returned when backend does not know it
+type Checksummer interface {
+	Checksum(dst string) (string, error)
+}
+
+// Resumer continues interrupted uploads of large files, UploadWhole uploads file from start instead
+type Resumer interface {
+	UploadWhole(src, dst string) error
+}
+
+// ErrMismatch is returned when uploaded file differs from local file
+var ErrMismatch = errors.New("uploaded file does not match")
+
+// put uploads file encrypted when enabled and records it in manifest of day when it was captured,
+// it returns remote path of uploaded file
+func (t *Target) put(src, dst string, meta map[string]string, when time.Time) (string, error) {
+	resume := true
+	if crypt.Uploads() {
+		encrypted, err := crypt.EncryptTemp(src)
+		if err != nil {
+			return dst, fmt.Errorf("cannot encrypt: %s", err)
+		}
+		defer os.Remove(encrypted)
+		// every encryption differs so that part left by earlier attempt cannot be continued
+		src, dst, resume = encrypted, dst+crypt.Suffix, false
+	}
+	if err := t.transfer(src, dst, meta, resume); err != nil {
+		return dst, err
+	}
+	if err := t.addToManifest(src, dst, when); err != nil {
+		log.Printf("Failed to add %s to manifest of %s: %s\n", dst, t.Name, err)
+	}
+	return dst, nil
+}
+
+// transfer uploads file and verifies it, mismatching upload is sent once again
+func (t *Target) transfer(src, dst string, meta map[string]string, resume bool) error {
+	err := t.store(src, dst, meta, resume)
+	if err == nil {
+		err = t.verify(src, dst)
+	}
+	if errors.Is(err, ErrMismatch) {
+		log.Printf("Upload to %s is corrupted, uploading again (%s): %s\n", t.Name, src, err)
+		if err = t.store(src, dst, meta, resume); err == nil {
+			err = t.verify(src, dst)
+		}
+	}
+	return err
+}
+
+func (t *Target) store(src, dst string, meta map[string]string, resume bool) error {
+	if mu, ok := t.Uploader.(MetadataUploader); ok && meta != nil {
+		return mu.UploadMetadata(src, dst, meta)
+	}
+	if r, ok := t.Uploader.(Resumer); ok && !resume {
+		return r.UploadWhole(src, dst)
+	}
+	return t.Upload(src, dst)
+}
+
+// verify compares size and checksum of uploaded file where target supports it
+func (t *Target) verify(src, dst string) error {
+	v, ok := t.Uploader.(Verifier)
+	if !ok {
+		return nil
+	}
+	fi, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	size, err := v.Size(dst)
+	if err != nil {
+		// servers without size support cannot be verified
+		return nil
+	}
+	if size != fi.Size() {
+		return fmt.Errorf("%w: remote size %d, local size %d", ErrMismatch, size, fi.Size())
+	}
+
+	c, ok := t.Uploader.(Checksummer)
+	if !ok {
+		return nil
+	}
+	remote, err := c.Checksum(dst)
+	if err != nil || remote == "" {
+		return nil
+	}
+	local, err := file.Checksum(src, md5.New())
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(remote, local) {
+		return fmt.Errorf("%w: remote MD5 %s, local MD5 %s", ErrMismatch, remote, local)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package upload
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"filippo.io/age"
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/crypt"
+	"github.com/kornelkabele/watchdog/internal/ftp"
+	"github.com/kornelkabele/watchdog/internal/ftp/ftptest"
+	"github.com/kornelkabele/watchdog/internal/local"
+	"github.com/kornelkabele/watchdog/internal/queue"
+)
+
+// truncating loses end of the first uploaded file as flaky connection would
+type truncating struct {
+	*local.Local
+	dir       string
+	truncated bool
+}
+
+func (u *truncating) Upload(src, dst string) error {
+	if err := u.Local.Upload(src, dst); err != nil {
+		return err
+	}
+	if u.truncated {
+		return nil
+	}
+	u.truncated = true
+	return os.Truncate(filepath.Join(u.dir, dst), 2)
+}
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	cfg.Settings.Id = "garden"
+	cfg.Settings.ManifestDir = filepath.Join(dir, "manifests")
+	remote := filepath.Join(dir, "remote")
+	u := &truncating{Local: local.New(cfg.ConfigTarget{Dir: remote}), dir: remote}
+	target := &Target{Name: "nas", Manifest: "{{.Camera}}/%Y-%m-%d/SHA256SUMS", Uploader: u}
+
+	src := filepath.Join(dir, "0007-0001.jpg")
+	if err := ioutil.WriteFile(src, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// frame captured before midnight is listed in manifest of its day even when uploaded later
+	captured := time.Now().AddDate(0, 0, -1)
+	dst := "garden/" + captured.Format("2006-01-02") + "/0007-0001.jpg"
+	if r := target.send(src, dst, nil, captured); r.Err != nil {
+		t.Fatal(r.Err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(remote, filepath.FromSlash(dst)))
+	if err != nil || string(data) != "jpeg" {
+		t.Fatalf("truncated upload was not repeated: %q, %v", data, err)
+	}
+
+	UploadManifests()
+	manifest, err := ioutil.ReadFile(filepath.Join(remote, "garden", captured.Format("2006-01-02"), "SHA256SUMS"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(manifest, []byte("  0007-0001.jpg\n")) || strings.Count(string(manifest), "\n") != 1 {
+		t.Errorf("unexpected manifest %q", manifest)
+	}
+}
+
+func TestEncryptedUploadRestarts(t *testing.T) {
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Encryption = cfg.ConfigEncryption{Recipients: []string{identity.Recipient().String()}, Uploads: true}
+	if err := crypt.Init(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		cfg.Encryption = cfg.ConfigEncryption{}
+		crypt.Init()
+	})
+
+	dir := t.TempDir()
+	openQueue(t, dir)
+	server, err := ftptest.NewServer(t.TempDir(), "camera", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	nas := ftp.New(cfg.ConfigTarget{Host: server.Host(), Port: server.Port(), User: "camera", Pass: "secret", TLSMode: "none"})
+	defer nas.Close()
+	Targets = []*Target{{Name: "nas", Uploader: nas}}
+
+	clip := bytes.Repeat([]byte("0123456789abcdef"), 1024*1024/8)
+	src := filepath.Join(dir, "clip.mp4")
+	if err := ioutil.WriteFile(src, clip, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// both attempts of the first upload are interrupted leaving part of different encryption on server
+	server.Interrupt(1024*1024, 1024*1024)
+	if r := Targets[0].send(src, "clip.mp4", nil, time.Now()); r.Err == nil || !r.Queued {
+		t.Fatalf("expected queued failure, got %v", r)
+	}
+	drain(newBackoff(), time.Now())
+	if items, _ := queue.Items(); len(items) != 0 {
+		t.Fatalf("queued upload was not retried: %v", items)
+	}
+	for _, stored := range server.Stored() {
+		if strings.Contains(stored, "@") {
+			t.Errorf("encrypted upload was resumed: %v", server.Stored())
+		}
+	}
+
+	restored := filepath.Join(dir, "restored.mp4")
+	if err := crypt.DecryptFile(filepath.Join(server.Root, "clip.mp4"+crypt.Suffix), restored, []age.Identity{identity}); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(restored); !bytes.Equal(data, clip) {
+		t.Error("decrypted upload differs from local file")
+	}
+}
@@ -0,0 +1,103 @@
+package video
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/system"
+)
+
+// Encoding describes how video is produced by ffmpeg command template
+type Encoding struct {
+	Command   string
+	Length    int
+	FrameRate int
+	Preset    string
+	Width     int
+	Timeout   time.Duration
+}
+
+// ClipEncoding returns encoding of event clips using given command template
+func ClipEncoding(command string) Encoding {
+	return Encoding{
+		Command:   command,
+		Length:    cfg.Clip.Length,
+		FrameRate: cfg.Clip.FrameRate,
+		Preset:    cfg.Clip.Preset,
+		Width:     cfg.Clip.MaxWidth,
+		Timeout:   time.Duration(cfg.Clip.Length)*time.Second + 2*time.Minute,
+	}
+}
+
+type clipCommand struct {
+	Clip      string
+	List      string
+	Length    int
+	FrameRate int
+	Preset    string
+	Width     int
+	cfg.ConfigCamera
+}
+
+func execute(clip, list string, enc Encoding) error {
+	data := clipCommand{
+		Clip:         clip,
+		List:         list,
+		Length:       enc.Length,
+		FrameRate:    enc.FrameRate,
+		Preset:       enc.Preset,
+		Width:        enc.Width,
+		ConfigCamera: cfg.Camera,
+	}
+	command, err := system.RenderCommand(enc.Command, data)
+	if err != nil {
+		return err
+	}
+	return system.ExecuteCommand(command, enc.Timeout)
+}
+
+// FromFrames assembles video from stored image files
+func FromFrames(frames []string, clip string, enc Encoding) error {
+	if len(frames) == 0 {
+		return fmt.Errorf("no frames for clip %s", clip)
+	}
+	list, err := writeFrameList(frames, enc.FrameRate)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(list)
+
+	return execute(clip, list, enc)
+}
+
+// Record records video of configured length straight from camera stream
+func Record(clip string, enc Encoding) error {
+	return execute(clip, "", enc)
+}
+
+// writeFrameList creates ffmpeg concat demuxer input with each frame shown for 1/frameRate seconds
+func writeFrameList(frames []string, frameRate int) (string, error) {
+	f, err := ioutil.TempFile("", "watchdog-clip-*.txt")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	duration := 1.0 / float64(frameRate)
+	for _, frame := range frames {
+		path, err := filepath.Abs(frame)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(f, "file '%s'\nduration %.3f\n", path, duration)
+	}
+	// concat demuxer ignores duration of the last entry unless it is repeated
+	path, _ := filepath.Abs(frames[len(frames)-1])
+	fmt.Fprintf(f, "file '%s'\n", path)
+
+	return f.Name(), nil
+}
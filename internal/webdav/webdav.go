@@ -0,0 +1,296 @@
+package webdav
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"github.com/kornelkabele/watchdog/internal/file"
+)
+
+// errConflict is returned when parent collection does not exist
+var errConflict = errors.New("parent collection does not exist")
+
+// WebDAV uploads files to WebDAV folder such as Nextcloud
+type WebDAV struct {
+	endpoint string
+	user     string
+	pass     string
+	client   *http.Client
+
+	mu          sync.Mutex
+	collections map[string]bool
+}
+
+// New creates WebDAV uploader of given target, app passwords are used the same way as basic auth passwords
+func New(target cfg.ConfigTarget) *WebDAV {
+	return &WebDAV{
+		endpoint:    strings.TrimSuffix(target.Endpoint, "/"),
+		user:        target.User,
+		pass:        target.Pass,
+		client:      &http.Client{Timeout: 5 * time.Minute},
+		collections: map[string]bool{},
+	}
+}
+
+// Upload uploads src file to dst path below endpoint
+func (w *WebDAV) Upload(src, dst string) error {
+	dst = strings.TrimPrefix(dst, "/")
+	err := w.upload(src, dst)
+	if err == errConflict {
+		// remote collection was removed since it was created, create it again
+		w.mu.Lock()
+		w.collections = map[string]bool{}
+		w.mu.Unlock()
+		err = w.upload(src, dst)
+	}
+	return err
+}
+
+func (w *WebDAV) upload(src, dst string) error {
+	if err := w.mkcolAll(path.Dir(dst)); err != nil {
+		return err
+	}
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	req, err := w.request(http.MethodPut, dst, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = fi.Size()
+	if contentType := mime.TypeByExtension(filepath.Ext(src)); contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	return w.do(req, http.StatusCreated, http.StatusNoContent, http.StatusOK)
+}
+
+// Size returns size of uploaded file
+func (w *WebDAV) Size(dst string) (int64, error) {
+	req, err := w.request(http.MethodHead, strings.TrimPrefix(dst, "/"), nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("HEAD %s failed: %s", req.URL.Path, resp.Status)
+	}
+	return resp.ContentLength, nil
+}
+
+// URL returns location of uploaded file
+func (w *WebDAV) URL(dst string) string {
+	return w.endpoint + "/" + escapePath(strings.TrimPrefix(dst, "/"))
+}
+
+// mkcolAll creates collection including missing parents, collections known to exist are skipped
+func (w *WebDAV) mkcolAll(dir string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	collection := ""
+	for _, part := range strings.Split(dir, "/") {
+		if part == "" || part == "." {
+			continue
+		}
+		collection = path.Join(collection, part)
+		if w.collections[collection] {
+			continue
+		}
+		req, err := w.request("MKCOL", collection+"/", nil)
+		if err != nil {
+			return err
+		}
+		// 405 is returned when collection already exists
+		if err := w.do(req, http.StatusCreated, http.StatusMethodNotAllowed); err != nil {
+			return err
+		}
+		w.collections[collection] = true
+	}
+	return nil
+}
+
+func (w *WebDAV) request(method, dst string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, w.URL(dst), body)
+	if err != nil {
+		return nil, err
+	}
+	if w.user != "" {
+		req.SetBasicAuth(w.user, w.pass)
+	}
+	return req, nil
+}
+
+func (w *WebDAV) do(req *http.Request, expected ...int) error {
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	for _, status := range expected {
+		if resp.StatusCode == status {
+			return nil
+		}
+	}
+	// servers answer 409 or 404 when parent collection is missing
+	if resp.StatusCode == http.StatusConflict || resp.StatusCode == http.StatusNotFound {
+		return errConflict
+	}
+	return fmt.Errorf("%s %s failed: %s", req.Method, req.URL.Path, resp.Status)
+}
+
+// propfindBody requests properties needed to walk collections
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// multistatus is PROPFIND response listing collection members
+type multistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength int64  `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// Walk calls fn for every file and collection below root
+func (w *WebDAV) Walk(root string, fn func(path string, info os.FileInfo) error) error {
+	return w.walk(strings.Trim(root, "/"), fn)
+}
+
+func (w *WebDAV) walk(dir string, fn func(path string, info os.FileInfo) error) error {
+	members, err := w.list(dir)
+	if err != nil {
+		return err
+	}
+	for _, m := range members {
+		if err := fn(m.path, m.info); err != nil {
+			return err
+		}
+		if m.info.IsDir() {
+			if err := w.walk(m.path, fn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// member is file or collection listed by PROPFIND
+type member struct {
+	path string
+	info os.FileInfo
+}
+
+// list returns members of collection, missing endpoint collection has none
+func (w *WebDAV) list(dir string) ([]member, error) {
+	collection := dir
+	if collection != "" {
+		collection += "/"
+	}
+	req, err := w.request("PROPFIND", collection, strings.NewReader(propfindBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Depth", "1")
+	req.Header.Set("Content-Type", "application/xml")
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && dir == "" {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus {
+		return nil, fmt.Errorf("PROPFIND %s failed: %s", req.URL.Path, resp.Status)
+	}
+	var ms multistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, err
+	}
+
+	base, _ := url.Parse(w.endpoint)
+	var members []member
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			return nil, err
+		}
+		// members are listed with absolute path, the first response is the collection itself
+		p := strings.Trim(strings.TrimPrefix(href.Path, base.Path), "/")
+		if p == dir || len(r.Propstat) == 0 {
+			continue
+		}
+		prop := r.Propstat[0].Prop
+		modTime, _ := http.ParseTime(prop.LastModified)
+		isDir := prop.ResourceType.Collection != nil
+		members = append(members, member{p, file.NewInfo(path.Base(p), prop.ContentLength, modTime, isDir)})
+	}
+	return members, nil
+}
+
+// Remove removes file or empty collection, DELETE of collection would remove its members as well
+func (w *WebDAV) Remove(dst string, dir bool) error {
+	dst = strings.TrimPrefix(dst, "/")
+	if dir {
+		members, err := w.list(strings.TrimSuffix(dst, "/"))
+		if err != nil {
+			return err
+		}
+		if len(members) > 0 {
+			return fmt.Errorf("collection %s is not empty", dst)
+		}
+		dst = strings.TrimSuffix(dst, "/") + "/"
+	}
+	req, err := w.request(http.MethodDelete, dst, nil)
+	if err != nil {
+		return err
+	}
+	err = w.do(req, http.StatusNoContent, http.StatusOK)
+	if dir {
+		w.mu.Lock()
+		w.collections = map[string]bool{}
+		w.mu.Unlock()
+	}
+	return err
+}
+
+// escapePath escapes each path segment keeping separators
+func escapePath(p string) string {
+	parts := strings.Split(p, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+	return strings.Join(parts, "/")
+}
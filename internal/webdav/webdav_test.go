@@ -0,0 +1,86 @@
+package webdav
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kornelkabele/watchdog/internal/cfg"
+	"golang.org/x/net/webdav"
+)
+
+func TestUpload(t *testing.T) {
+	root := t.TempDir()
+	handler := &webdav.Handler{FileSystem: webdav.Dir(root), LockSystem: webdav.NewMemLS()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "pi" || pass != "app-password" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	src := filepath.Join(t.TempDir(), "0007-0001.jpg")
+	if err := ioutil.WriteFile(src, []byte("jpeg"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(cfg.ConfigTarget{Endpoint: server.URL + "/", User: "pi", Pass: "app-password"})
+	for _, dst := range []string{"garden/2021/03/14/0007-0001.jpg", "garden/2021/03/14/0007-0002.jpg"} {
+		if err := w.Upload(src, dst); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(filepath.Join(root, filepath.FromSlash(dst)))
+		if err != nil || string(data) != "jpeg" {
+			t.Errorf("unexpected remote file %s: %q, %v", dst, data, err)
+		}
+	}
+
+	// collection removed on server is created again
+	if err := os.RemoveAll(filepath.Join(root, "garden")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Upload(src, "garden/2021/03/14/0007-0003.jpg"); err != nil {
+		t.Fatal(err)
+	}
+
+	var listed []string
+	err := w.Walk("garden/2021", func(p string, info os.FileInfo) error {
+		if !info.IsDir() && info.Size() != 4 {
+			t.Errorf("unexpected size of %s: %d", p, info.Size())
+		}
+		listed = append(listed, p)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listed) != 3 || listed[0] != "garden/2021/03" || listed[2] != "garden/2021/03/14/0007-0003.jpg" {
+		t.Errorf("unexpected listing %v", listed)
+	}
+	// collection with files left is kept
+	if err := w.Remove("garden/2021/03/14", true); err == nil {
+		t.Error("expected removal of collection with files to fail")
+	}
+	if _, err := os.Stat(filepath.Join(root, "garden", "2021", "03", "14", "0007-0003.jpg")); err != nil {
+		t.Errorf("file of kept collection was removed: %v", err)
+	}
+	if err := w.Remove("garden/2021/03/14/0007-0003.jpg", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Remove("garden/2021/03/14", true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(root, "garden", "2021", "03", "14")); !os.IsNotExist(err) {
+		t.Errorf("collection was not removed: %v", err)
+	}
+
+	denied := New(cfg.ConfigTarget{Endpoint: server.URL, User: "pi", Pass: "wrong"})
+	if err := denied.Upload(src, "0007-0001.jpg"); err == nil {
+		t.Error("expected unauthorized upload to fail")
+	}
+}
@@ -0,0 +1,120 @@
+package worker
+
+import (
+	"log"
+	"sync"
+)
+
+// urgentFactor bounds urgent jobs to this multiple of queue size so that queue does not grow
+// without limit while delivery is down
+const urgentFactor = 5
+
+// Job is a unit of delivery work such as upload of a frame or sending of a notification
+type Job struct {
+	// Urgent jobs such as alerts are dropped only when the hard limit of queue is reached
+	Urgent bool
+	Run    func()
+	// Drop is called instead of Run when job is dropped because queue is full
+	Drop func()
+}
+
+// Queue is a bounded job queue consumed by worker goroutines. When it is full the oldest
+// routine job is dropped to make room, urgent jobs are kept above the limit up to urgentFactor
+// times its size, then the oldest one is dropped.
+type Queue struct {
+	name string
+	size int
+
+	mu   sync.Mutex
+	cond *sync.Cond
+	jobs []Job
+}
+
+// NewQueue creates queue holding at most size routine jobs
+func NewQueue(name string, size int) *Queue {
+	if size < 1 {
+		size = 1
+	}
+	q := &Queue{name: name, size: size}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// Start runs given number of worker goroutines consuming the queue
+func (q *Queue) Start(workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+}
+
+// Push adds job to the end of queue, it never blocks
+func (q *Queue) Push(job Job) {
+	q.mu.Lock()
+	var dropped *Job
+	if len(q.jobs) >= q.size {
+		i := q.oldestRoutine()
+		switch {
+		case i >= 0:
+		case !job.Urgent:
+			// a full queue of urgent jobs does not leave room for routine one
+			dropped = &job
+		case len(q.jobs) >= q.size*urgentFactor:
+			i = 0
+		}
+		if i >= 0 {
+			j := q.jobs[i]
+			dropped = &j
+			q.jobs = append(q.jobs[:i], q.jobs[i+1:]...)
+		}
+	}
+	if dropped != &job {
+		q.jobs = append(q.jobs, job)
+		q.cond.Signal()
+	}
+	q.mu.Unlock()
+
+	if dropped != nil {
+		kind := "routine"
+		if dropped.Urgent {
+			kind = "urgent"
+		}
+		log.Printf("Queue %s is full, dropping %s job\n", q.name, kind)
+		if dropped.Drop != nil {
+			dropped.Drop()
+		}
+	}
+}
+
+// oldestRoutine returns index of the oldest routine job or -1 when all jobs are urgent
+func (q *Queue) oldestRoutine() int {
+	for i := range q.jobs {
+		if !q.jobs[i].Urgent {
+			return i
+		}
+	}
+	return -1
+}
+
+// Len returns number of waiting jobs
+func (q *Queue) Len() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return len(q.jobs)
+}
+
+func (q *Queue) work() {
+	for {
+		q.mu.Lock()
+		for len(q.jobs) == 0 {
+			q.cond.Wait()
+		}
+		job := q.jobs[0]
+		q.jobs = q.jobs[1:]
+		q.mu.Unlock()
+
+		job.Run()
+	}
+}
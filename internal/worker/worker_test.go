@@ -0,0 +1,64 @@
+package worker
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestDropOldestRoutine(t *testing.T) {
+	q := NewQueue("test", 3)
+	var mu sync.Mutex
+	var ran, dropped []string
+	var wg sync.WaitGroup
+	job := func(name string, urgent bool) Job {
+		return Job{
+			Urgent: urgent,
+			Run: func() {
+				mu.Lock()
+				ran = append(ran, name)
+				mu.Unlock()
+				wg.Done()
+			},
+			Drop: func() { dropped = append(dropped, name) },
+		}
+	}
+
+	// no workers yet so that queue fills up
+	q.Push(job("routine-1", false))
+	q.Push(job("alert-1", true))
+	q.Push(job("routine-2", false))
+	q.Push(job("routine-3", false))
+	q.Push(job("alert-2", true))
+	q.Push(job("alert-3", true))
+	q.Push(job("alert-4", true))
+	q.Push(job("routine-4", false))
+
+	if len(dropped) != 4 || dropped[0] != "routine-1" || dropped[1] != "routine-2" || dropped[3] != "routine-4" {
+		t.Errorf("unexpected dropped jobs %v", dropped)
+	}
+	if q.Len() != 4 {
+		t.Errorf("expected alerts kept above limit, got %d jobs", q.Len())
+	}
+
+	wg.Add(q.Len())
+	q.Start(2)
+	wg.Wait()
+	if len(ran) != 4 {
+		t.Errorf("unexpected jobs run %v", ran)
+	}
+}
+
+func TestUrgentLimit(t *testing.T) {
+	q := NewQueue("test", 2)
+	var dropped []int
+	for i := 0; i < 2*urgentFactor+2; i++ {
+		i := i
+		q.Push(Job{Urgent: true, Run: func() {}, Drop: func() { dropped = append(dropped, i) }})
+	}
+	if q.Len() != 2*urgentFactor {
+		t.Errorf("expected urgent jobs capped at %d, got %d", 2*urgentFactor, q.Len())
+	}
+	if len(dropped) != 2 || dropped[0] != 0 || dropped[1] != 1 {
+		t.Errorf("expected oldest urgent jobs dropped, got %v", dropped)
+	}
+}